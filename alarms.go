@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dkt64/tagsgenerator/pkg/alarms"
+)
+
+// timeLayouts - formaty czasu w parametrach -from i -to
+// ================================================================================================
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"}
+
+// parseTime - czas z parametru (czas lokalny, gdy bez strefy)
+// ================================================================================================
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected e.g. 2006-01-02 or \"2006-01-02 15:04\"", s)
+}
+
+// runAlarms - polecenie alarms z podpoleceniami
+// tagsgenerator alarms history [-db alarms.db] [-from ...] [-to ...] [-number n] [-csv file]
+// ================================================================================================
+func runAlarms(args []string) {
+
+	if len(args) == 0 {
+		fail(exitUsage, errors.New("usage: tagsgenerator alarms history [options]"))
+	}
+
+	switch args[0] {
+	case "history":
+		runAlarmsHistory(args[1:])
+	default:
+		fail(exitUsage, fmt.Errorf("unknown alarms command %q", args[0]))
+	}
+}
+
+// runAlarmsHistory - wystąpienia alarmów z pliku historii w zakresie czasu
+// ================================================================================================
+func runAlarmsHistory(args []string) {
+
+	fs := flag.NewFlagSet("alarms history", flag.ExitOnError)
+	dbFilename := fs.String("db", "alarms.db", "Alarm history database file (input)")
+	from := fs.String("from", "", "Show alarms active at or after this time, e.g. 2006-01-02 or \"2006-01-02 15:04\"")
+	to := fs.String("to", "", "Show alarms that came before this time")
+	number := fs.String("number", "", "Show only the alarm with this number, A prefix for analog alarms, e.g. 12 or A12")
+	lang := fs.String("lang", "", "Language of alarm texts, e.g. de-DE (default: first text)")
+	csvFilename := fs.String("csv", "", "Export alarms to a CSV file (output), - for standard output")
+	summary := fs.Bool("summary", false, "Print count and total duration per alarm")
+	fs.Parse(args)
+
+	var q alarms.Query
+	var err error
+	if q.From, err = parseTime(*from); err != nil {
+		fail(exitUsage, err)
+	}
+	if q.To, err = parseTime(*to); err != nil {
+		fail(exitUsage, err)
+	}
+	if *number != "" {
+		var analog bool
+		if q.Number, analog, err = alarms.ParseNumber(*number); err != nil {
+			fail(exitUsage, err)
+		}
+		q.Analog = &analog
+	}
+
+	if !fileExists(*dbFilename) {
+		fail(exitError, fmt.Errorf("%s: no such file", *dbFilename))
+	}
+	history, err := alarms.OpenHistory(*dbFilename)
+	if err != nil {
+		fail(exitError, err)
+	}
+	defer history.Close()
+
+	records, err := history.Query(q)
+	if err != nil {
+		fail(exitError, err)
+	}
+
+	if *csvFilename != "" {
+		if err := writeHistoryCSV(*csvFilename, records, *lang); err != nil {
+			fail(exitError, err)
+		}
+		if *csvFilename == "-" {
+			return
+		}
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	if *summary {
+		fmt.Fprintln(w, "NUMBER\tCOUNT\tDURATION\tTEXT")
+		for _, s := range alarms.Summarize(records, *lang, now) {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", alarms.FormatNumber(s.Number, s.Analog), s.Count, s.Duration.Round(time.Second), s.Text)
+		}
+		w.Flush()
+		return
+	}
+
+	fmt.Fprintln(w, "NUMBER\tCAME\tWENT\tACKNOWLEDGED\tDURATION\tTEXT")
+	for _, r := range records {
+		duration := r.Duration(now).Round(time.Millisecond).String()
+		if r.Went.IsZero() {
+			duration += " (active)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", alarms.FormatNumber(r.Number, r.Analog), historyTime(r.Came), historyTime(r.Went), historyTime(r.Acked), duration, alarms.Text(r.Texts, *lang))
+	}
+	w.Flush()
+
+	fmt.Printf("%d alarms\n", len(records))
+}
+
+// historyTime - czas w tabeli historii; "-" dla zerowego czasu
+// ================================================================================================
+func historyTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04:05.000")
+}
+
+// writeHistoryCSV - eksport historii alarmów do pliku CSV ("-" - standardowe wyjście)
+// ================================================================================================
+func writeHistoryCSV(filename string, records []alarms.Record, lang string) error {
+
+	var w io.Writer = os.Stdout
+	if filename != "-" {
+		file, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return alarms.WriteCSV(w, records, lang)
+}
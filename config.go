@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// Pliki wyjściowe generatora (lista outputs w pliku projektu)
+// ================================================================================================
+const (
+	outputPLC     = "plc"
+	outputIOT     = "iot"
+	outputTags    = "tags"
+	outputAlarms  = "alarms"
+	outputAgent   = "agent"
+	outputChannel = "channel"
+
+	outputUnresolved = "unresolved"
+)
+
+var allOutputs = []string{outputPLC, outputIOT, outputTags, outputAlarms, outputAgent, outputChannel, outputUnresolved}
+
+// agentConfig - agent IoT Gateway w pliku projektu
+// ================================================================================================
+type agentConfig struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Topic    string `json:"topic"`
+	QoS      int    `json:"qos"`
+	ClientID string `json:"client_id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Rate     int    `json:"rate"`
+	OnChange bool   `json:"on_change"`
+	Template string `json:"template"`
+	Port     int    `json:"port"`
+}
+
+// deviceConfig - kanał i urządzenie w pliku projektu
+// ================================================================================================
+type deviceConfig struct {
+	Model      string `json:"model"`
+	IP         string `json:"ip"`
+	Port       int    `json:"port"`
+	Rack       int    `json:"rack"`
+	Slot       int    `json:"slot"`
+	LocalTSAP  string `json:"local_tsap"`
+	RemoteTSAP string `json:"remote_tsap"`
+}
+
+// machineConfig - jedno generowanie: pliki wejściowe i wyjściowe, filtry i parametry połączenia
+// ================================================================================================
+type machineConfig struct {
+	Name string `json:"name"`
+	Dir  string `json:"dir"`
+
+	Symbols  string `json:"symbols"`
+	Tags     string `json:"tags"`
+	Alarms   string `json:"alarms"`
+	Baseline string `json:"baseline"`
+
+	TextLists     string `json:"textlists"`
+	TextListLinks string `json:"textlist_links"`
+
+	PLC         string   `json:"plc"`
+	IOT         string   `json:"iot"`
+	TagsJSON    string   `json:"tags_json"`
+	AlarmsJSON  string   `json:"alarms_json"`
+	AgentFile   string   `json:"agent_file"`
+	ChannelFile string   `json:"channel_file"`
+	Outputs     []string `json:"outputs"`
+
+	UnresolvedFile string `json:"unresolved_file"`
+	FailUnresolved bool   `json:"fail_unresolved"`
+
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+	Strict  bool     `json:"strict"`
+
+	Connection  string  `json:"connection"`
+	BlockSize   int     `json:"block_size"`
+	ScanRate    int     `json:"scan_rate"`
+	Mode        string  `json:"mode"`
+	PDU         int     `json:"pdu"`
+	Gap         int     `json:"gap"`
+	Optimize    bool    `json:"optimize"`
+	RequestCost float64 `json:"request_cost"`
+	ByteCost    float64 `json:"byte_cost"`
+
+	Languages        []string `json:"languages"`
+	FallbackLanguage string   `json:"fallback_language"`
+
+	Agent  agentConfig  `json:"agent"`
+	Device deviceConfig `json:"device"`
+}
+
+// defaultMachine - parametry domyślne (jak flagi programu)
+// ================================================================================================
+func defaultMachine() machineConfig {
+
+	defaults := tagsgen.DefaultOptions()
+	a, d := defaults.Agent, defaults.Device
+
+	return machineConfig{
+		PLC:         "plc.csv",
+		IOT:         "iot.csv",
+		TagsJSON:    "tags.json",
+		AlarmsJSON:  "alarms.json",
+		AgentFile:   "iot_agent.json",
+		ChannelFile: "channel.json",
+		Outputs:     append([]string(nil), allOutputs...),
+		Connection:  defaults.ConnectionName,
+		BlockSize:   defaults.BlockSize,
+		ScanRate:    defaults.ScanRate,
+		Mode:        defaults.Mode,
+		RequestCost: defaults.RequestCost,
+		ByteCost:    defaults.ByteCost,
+		Agent:       agentConfig{Name: a.Name, Topic: a.Topic, QoS: a.QoS, ClientID: a.ClientID, Rate: a.Rate, Port: a.Port},
+		Device:      deviceConfig{Model: d.Model, Port: d.Port, Rack: d.Rack, Slot: d.Slot},
+
+		UnresolvedFile: "unresolved_alarms.json",
+	}
+}
+
+// options - parametry generowania
+// ================================================================================================
+func (m *machineConfig) options() tagsgen.Options {
+	a, d := m.Agent, m.Device
+	return tagsgen.Options{
+		ConnectionName: m.Connection,
+		BlockSize:      m.BlockSize,
+		ScanRate:       m.ScanRate,
+		Mode:           m.Mode,
+		PDUSize:        m.PDU,
+		GapTolerance:   m.Gap,
+		Optimize:       m.Optimize,
+		RequestCost:    m.RequestCost,
+		ByteCost:       m.ByteCost,
+
+		Languages:        m.Languages,
+		FallbackLanguage: m.FallbackLanguage,
+
+		Agent: tagsgen.AgentOptions{
+			Type: a.Type, Name: a.Name, URL: a.URL, Topic: a.Topic, QoS: a.QoS, ClientID: a.ClientID,
+			Username: a.Username, Password: a.Password, Rate: a.Rate, OnChange: a.OnChange, Template: a.Template, Port: a.Port,
+		},
+		Device: tagsgen.DeviceOptions{
+			Model: d.Model, IP: d.IP, Port: d.Port, Rack: d.Rack, Slot: d.Slot, LocalTSAP: d.LocalTSAP, RemoteTSAP: d.RemoteTSAP,
+		},
+	}
+}
+
+// path - ścieżka pliku względem katalogu maszyny
+// ================================================================================================
+func (m *machineConfig) path(filename string) string {
+	if filename == "" || m.Dir == "" || filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(m.Dir, filename)
+}
+
+// writes - czy plik wyjściowy jest na liście outputs
+// ================================================================================================
+func (m *machineConfig) writes(output string) bool {
+	for _, o := range m.Outputs {
+		if strings.EqualFold(o, output) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeConfig - wartości maszyny nałożone na wartości wspólne (z zagnieżdżonymi obiektami)
+// ================================================================================================
+func mergeConfig(base map[string]interface{}, over map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range over {
+		if b, ok := merged[k].(map[string]interface{}); ok {
+			if o, ok := v.(map[string]interface{}); ok {
+				merged[k] = mergeConfig(b, o)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// loadConfig - maszyny z pliku projektu (YAML, JSON lub TOML wg rozszerzenia)
+// Wartości na najwyższym poziomie są wspólne dla wszystkich maszyn z listy machines; bez listy
+// plik opisuje jedną maszynę. Ścieżki są względne wobec katalogu pliku projektu i dir maszyny.
+// ================================================================================================
+func loadConfig(filename string) ([]machineConfig, error) {
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	case ".toml":
+		var m map[string]interface{}
+		_, err = toml.Decode(string(data), &m)
+		doc = m
+	default:
+		return nil, fmt.Errorf("%s: unknown project file format, expected .yaml, .json or .toml", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	// ujednolicenie typów map i list z różnych formatów
+	normalized, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(normalized, &root); err != nil {
+		return nil, fmt.Errorf("%s: project file must be an object: %w", filename, err)
+	}
+
+	var entries []map[string]interface{}
+	if list, ok := root["machines"]; ok {
+		items, ok := list.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: machines must be a list", filename)
+		}
+		delete(root, "machines")
+		for _, item := range items {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: every machine must be an object", filename)
+			}
+			entries = append(entries, mergeConfig(root, entry))
+		}
+	} else {
+		entries = append(entries, root)
+	}
+
+	base := filepath.Dir(filename)
+	var machines []machineConfig
+	for i, entry := range entries {
+		m := defaultMachine()
+
+		data, _ := json.Marshal(entry)
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("%s: machine %d: %w", filename, i+1, err)
+		}
+
+		// z pdu bez block_size bloki mają rozmiar całego PDU
+		if _, ok := entry["block_size"]; !ok && m.PDU > 0 {
+			m.BlockSize = 0
+		}
+		if m.Name == "" {
+			m.Name = m.Connection
+		}
+		if !filepath.IsAbs(m.Dir) {
+			m.Dir = filepath.Join(base, m.Dir)
+		}
+		machines = append(machines, m)
+	}
+
+	return machines, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/dkt64/tagsgenerator/pkg/kepware"
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// runDeploy - polecenie deploy: konfiguracja KEPServerEX przez Configuration REST API
+// tagsgenerator deploy [-server http://localhost:57412] [-plc plc.csv] [-agent iot_agent.json] [-dry-run]
+// [-delete-stale previous_plc.csv]
+// ================================================================================================
+func runDeploy(args []string) {
+
+	defaults := tagsgen.DefaultOptions()
+
+	fs := flag.NewFlagSet("deploy", flag.ExitOnError)
+	server := fs.String("server", "http://localhost:57412", "KEPServerEX Configuration API address")
+	user := fs.String("user", "Administrator", "Configuration API user name")
+	password := fs.String("password", "", "Configuration API password")
+	connectionName := fs.String("c", defaults.ConnectionName, "Connection description (<channel>.<device>)")
+	plcFilename := fs.String("plc", "plc.csv", "PLC Tags filename (input)")
+	channelFilename := fs.String("channel", "channel.json", "Kepware channel and device definition filename (input), skipped when missing")
+	agentFilename := fs.String("agent", "iot_agent.json", "IoT Gateway agent definition filename (input), skipped when missing")
+	dryRun := fs.Bool("dry-run", false, "Only print the changes, do not modify the server configuration")
+	previousFilename := fs.String("delete-stale", "", "plc.csv of the previous generation (input) - delete its tags and IoT Gateway items which are no longer generated")
+	fs.Parse(args)
+
+	tags, err := tagsgen.LoadConfigTagsFile(*plcFilename)
+	if err != nil {
+		fail(exitError, err)
+	}
+
+	d := kepware.Deployment{
+		ConnectionName: *connectionName,
+		Tags:           tags,
+	}
+	if *previousFilename != "" {
+		previous, err := tagsgen.LoadConfigTagsFile(*previousFilename)
+		if err != nil {
+			fail(exitError, err)
+		}
+		for _, t := range previous {
+			d.PreviousTags = append(d.PreviousTags, t.Name())
+		}
+	}
+	if fileExists(*channelFilename) {
+		if err := readJSON(*channelFilename, &d.Channel); err != nil {
+			fail(exitError, fmt.Errorf("%s: %w", *channelFilename, err))
+		}
+		devices, err := kepware.Children(d.Channel, "devices")
+		if err != nil {
+			fail(exitError, fmt.Errorf("%s: %w", *channelFilename, err))
+		}
+		if len(devices) > 0 {
+			d.Device = devices[0]
+		}
+	}
+	if fileExists(*agentFilename) {
+		if err := readJSON(*agentFilename, &d.Agent); err != nil {
+			fail(exitError, fmt.Errorf("%s: %w", *agentFilename, err))
+		}
+	}
+
+	client := kepware.NewClient(*server, *user, *password)
+	client.DryRun = *dryRun
+
+	if *dryRun {
+		fmt.Printf("Dry run against %s, nothing is changed\n", *server)
+	}
+
+	actions, err := client.Deploy(d)
+
+	counts := make(map[string]int)
+	for _, a := range actions {
+		fmt.Println("  " + a.String())
+		counts[a.Type]++
+	}
+	if err != nil {
+		fail(exitError, err)
+	}
+	fmt.Printf("%d tags, %d changes: %d created, %d updated, %d deleted\n", len(tags), len(actions), counts[kepware.ActionCreate], counts[kepware.ActionUpdate], counts[kepware.ActionDelete])
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/dkt64/tagsgenerator/pkg/alarms"
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// inputFile - pierwszy istniejący plik z listy w katalogu
+// ================================================================================================
+func inputFile(dir string, names ...string) string {
+	for _, name := range names {
+		if fileExists(filepath.Join(dir, name)) {
+			return filepath.Join(dir, name)
+		}
+	}
+	return ""
+}
+
+// readJSON - odczyt pliku json
+// ================================================================================================
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// loadDiffSide - tagi i alarmy jednej strony porównania
+// Ścieżka to plik tags.json (alarms.json obok niego), katalog z plikami wejściowymi (Symbols.asc,
+// Tags.csv, Alarms.csv, ... lub pliki maszyny m) - projekt jest generowany jak przez generator
+// z parametrami i plikami maszyny m względem tego katalogu - albo katalog z tags.json i alarms.json.
+// ================================================================================================
+func loadDiffSide(path string, m machineConfig) (tags tagsgen.Tags, alarms tagsgen.Alarms, err error) {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	dir := path
+	if !info.IsDir() {
+		dir = filepath.Dir(path)
+	} else {
+		m.Dir = dir
+
+		hasInputs := inputFile(dir, "Symbols.asc", "PLCTags.sdf", "Tags.csv", "HMITags.xlsx") != ""
+		for _, filename := range []string{m.Symbols, m.Tags} {
+			hasInputs = hasInputs || (filename != "" && fileExists(m.path(filename)))
+		}
+
+		if hasInputs {
+			var project *tagsgen.Project
+			if project, _, err = loadProject(os.Stderr, &m); err != nil {
+				return
+			}
+
+			var result *tagsgen.Result
+			result, err = project.Generate(m.options())
+			if err != nil {
+				return
+			}
+			return result.Tags, result.Alarms, nil
+		}
+
+		path = filepath.Join(dir, "tags.json")
+	}
+
+	if err = readJSON(path, &tags); err != nil {
+		return
+	}
+	if alarmsFilename := inputFile(dir, "alarms.json"); alarmsFilename != "" {
+		err = readJSON(alarmsFilename, &alarms)
+	}
+	return
+}
+
+// diffMachine - maszyna z pliku projektu: o podanej nazwie albo jedyna w pliku
+// ================================================================================================
+func diffMachine(filename string, name string) (machineConfig, error) {
+
+	machines, err := loadConfig(filename)
+	if err != nil {
+		return machineConfig{}, err
+	}
+	for _, m := range machines {
+		if m.Name == name || (name == "" && len(machines) == 1) {
+			return m, nil
+		}
+	}
+	if name == "" {
+		return machineConfig{}, fmt.Errorf("%s: %d machines, choose one with -machine", filename, len(machines))
+	}
+	return machineConfig{}, fmt.Errorf("%s: no machine %q", filename, name)
+}
+
+// tagPointer - miejsce symbolu w tagach Kepware: "tabDB18_56[0].3"
+// ================================================================================================
+func tagPointer(t tagsgen.CsvTag) string {
+	return fmt.Sprintf("%s[%d].%d", t.TagName, t.Index, t.BitNr)
+}
+
+// printDiff - raport różnic w formie czytelnej
+// ================================================================================================
+func printDiff(w io.Writer, d *tagsgen.Diff) {
+
+	if d.Empty() {
+		fmt.Fprintln(w, "No differences")
+		return
+	}
+
+	if len(d.AddedSymbols) > 0 {
+		fmt.Fprintf(w, "Added symbols (%d):\n", len(d.AddedSymbols))
+		for _, t := range d.AddedSymbols {
+			fmt.Fprintf(w, "  + %-40s %-14s %s\n", t.SymbolName, tagsgen.SymbolAddress(t), tagPointer(t))
+		}
+	}
+	if len(d.RemovedSymbols) > 0 {
+		fmt.Fprintf(w, "Removed symbols (%d):\n", len(d.RemovedSymbols))
+		for _, t := range d.RemovedSymbols {
+			fmt.Fprintf(w, "  - %-40s %-14s %s\n", t.SymbolName, tagsgen.SymbolAddress(t), tagPointer(t))
+		}
+	}
+	if len(d.ChangedAddresses) > 0 {
+		fmt.Fprintf(w, "Changed addresses (%d):\n", len(d.ChangedAddresses))
+		for _, c := range d.ChangedAddresses {
+			fmt.Fprintf(w, "  ~ %-40s %s -> %s\n", c.SymbolName, tagsgen.SymbolAddress(c.Old), tagsgen.SymbolAddress(c.New))
+		}
+	}
+	if len(d.MovedSymbols) > 0 {
+		fmt.Fprintf(w, "Moved symbols (%d):\n", len(d.MovedSymbols))
+		for _, c := range d.MovedSymbols {
+			fmt.Fprintf(w, "  > %-40s %s -> %s\n", c.SymbolName, tagPointer(c.Old), tagPointer(c.New))
+		}
+	}
+	if len(d.RenamedBlocks) > 0 {
+		fmt.Fprintf(w, "Renamed block tags (%d):\n", len(d.RenamedBlocks))
+		for _, r := range d.RenamedBlocks {
+			fmt.Fprintf(w, "  > %s -> %s\n", r.OldName, r.NewName)
+		}
+	}
+	if len(d.AddedAlarms) > 0 {
+		fmt.Fprintf(w, "Added alarms (%d):\n", len(d.AddedAlarms))
+		for _, a := range d.AddedAlarms {
+			fmt.Fprintf(w, "  + %s %s\n", alarms.FormatNumber(a.Number, a.Analog != nil), a.Texts)
+		}
+	}
+	if len(d.RemovedAlarms) > 0 {
+		fmt.Fprintf(w, "Removed alarms (%d):\n", len(d.RemovedAlarms))
+		for _, a := range d.RemovedAlarms {
+			fmt.Fprintf(w, "  - %s %s\n", alarms.FormatNumber(a.Number, a.Analog != nil), a.Texts)
+		}
+	}
+	if len(d.ChangedAlarmTexts) > 0 {
+		fmt.Fprintf(w, "Changed alarm texts (%d):\n", len(d.ChangedAlarmTexts))
+		for _, c := range d.ChangedAlarmTexts {
+			number := alarms.FormatNumber(c.Number, c.Analog)
+			fmt.Fprintf(w, "  ~ %s %s\n", number, c.OldTexts)
+			fmt.Fprintf(w, "    %*s %s\n", len(number), "", c.NewTexts)
+		}
+	}
+}
+
+// runDiff - polecenie diff: porównanie dwóch projektów
+// tagsgenerator diff [-json] [-o file] OLD NEW
+// ================================================================================================
+func runDiff(args []string) {
+
+	defaults := tagsgen.DefaultOptions()
+
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: tagsgenerator diff [options] OLD NEW")
+		fmt.Fprintln(fs.Output(), "OLD and NEW are tags.json files or folders with input files (Symbols.asc, Tags.csv, Alarms.csv, ...) or with tags.json and alarms.json")
+		fs.PrintDefaults()
+	}
+	jsonOut := fs.Bool("json", false, "Print differences as JSON")
+	outFilename := fs.String("o", "", "Write differences to file instead of stdout")
+	connectionName := fs.String("c", defaults.ConnectionName, "Connection description (for input files)")
+	blockSize := fs.Int("b", defaults.BlockSize, "Block size in [bytes] (for input files)")
+	genMode := fs.String("mode", defaults.Mode, "Generation mode (for input files)")
+	pduSize := fs.Int("pdu", 0, "Negotiated PLC PDU size in [bytes] (for input files)")
+	gapTolerance := fs.Int("gap", 0, "Merge blocks separated by up to this many unused bytes (for input files)")
+	optimize := fs.Bool("optimize", false, "Partition blocks with minimal cost of S7 read requests (for input files)")
+	requestCost := fs.Float64("request-cost", defaults.RequestCost, "Cost of one S7 read request for -optimize (for input files)")
+	byteCost := fs.Float64("byte-cost", defaults.ByteCost, "Cost of one transferred byte for -optimize (for input files)")
+	textListsFilename := fs.String("textlists", "", "Text lists filename in the folder (for input files), default: Textlists.csv when present")
+	textListLinksFilename := fs.String("textlist-links", "", "Links of HMI tags to text lists filename in the folder (for input files)")
+	languages := fs.String("languages", "", "Comma separated languages of alarm and text list texts (for input files)")
+	fallbackLanguage := fs.String("fallback-lang", "", "Language of texts used for empty translations (for input files)")
+	baselineFilename := fs.String("baseline", "", "Previous plc.csv or tags.json in the folder (for input files)")
+	configFilename := fs.String("config", "", "Project file with inputs and options (for input files); paths are relative to OLD and NEW")
+	machineName := fs.String("machine", "", "Machine of the project file with more than one machine")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+
+	m := defaultMachine()
+	if *configFilename != "" {
+		var err error
+		if m, err = diffMachine(*configFilename, *machineName); err != nil {
+			fail(exitUsage, err)
+		}
+	}
+
+	// podane flagi zastępują wartości z pliku projektu; z -pdu bez -b bloki mają rozmiar całego PDU
+	blockSet := false
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "c":
+			m.Connection = *connectionName
+		case "b":
+			m.BlockSize, blockSet = *blockSize, true
+		case "mode":
+			m.Mode = *genMode
+		case "pdu":
+			m.PDU = *pduSize
+		case "gap":
+			m.Gap = *gapTolerance
+		case "optimize":
+			m.Optimize = *optimize
+		case "request-cost":
+			m.RequestCost = *requestCost
+		case "byte-cost":
+			m.ByteCost = *byteCost
+		case "textlists":
+			m.TextLists = *textListsFilename
+		case "textlist-links":
+			m.TextListLinks = *textListLinksFilename
+		case "languages":
+			m.Languages = splitList(*languages)
+		case "fallback-lang":
+			m.FallbackLanguage = *fallbackLanguage
+		case "baseline":
+			m.Baseline = *baselineFilename
+		}
+	})
+	if *pduSize > 0 && !blockSet {
+		m.BlockSize = 0
+	}
+
+	if err := diffProjects(fs.Arg(0), fs.Arg(1), m, *outFilename, *jsonOut); err != nil {
+		fail(exitError, err)
+	}
+}
+
+// diffProjects - porównanie projektów oldPath i newPath i zapis różnic do pliku outFilename
+// (pusty - stdout)
+// ================================================================================================
+func diffProjects(oldPath string, newPath string, m machineConfig, outFilename string, jsonOut bool) error {
+
+	oldTags, oldAlarms, err := loadDiffSide(oldPath, m)
+	if err != nil {
+		return fmt.Errorf("%s: %w", oldPath, err)
+	}
+	newTags, newAlarms, err := loadDiffSide(newPath, m)
+	if err != nil {
+		return fmt.Errorf("%s: %w", newPath, err)
+	}
+
+	d := tagsgen.Compare(oldTags, oldAlarms, newTags, newAlarms)
+
+	if outFilename == "" {
+		return writeDiff(os.Stdout, d, jsonOut)
+	}
+
+	file, err := os.Create(outFilename)
+	if err != nil {
+		return err
+	}
+	if err := writeDiff(file, d, jsonOut); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeDiff - różnice jako JSON lub raport czytelny
+// ================================================================================================
+func writeDiff(w io.Writer, d *tagsgen.Diff, jsonOut bool) error {
+
+	if !jsonOut {
+		printDiff(w, d)
+		return nil
+	}
+
+	data, err := json.MarshalIndent(d, "", " ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// writeJSONFile - zapis v do pliku json w katalogu dir
+// ================================================================================================
+func writeJSONFile(t *testing.T, dir string, name string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// diffSide - katalog z tags.json i alarms.json
+// ================================================================================================
+func diffSide(t *testing.T, tags []tagsgen.CsvTag, alarms []tagsgen.CsvAlarm) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeJSONFile(t, dir, "tags.json", tagsgen.Tags{Tags: tags})
+	writeJSONFile(t, dir, "alarms.json", tagsgen.Alarms{Alarms: alarms})
+	return dir
+}
+
+func TestLoadDiffSide(t *testing.T) {
+
+	tags := []tagsgen.CsvTag{{SymbolName: "Start", SymbolPeriph: "I", SymbolAddressHi: "10", SymbolAddressLo: "2", TagName: "tabIB_0", DataType: "Byte Array"}}
+	alarms := []tagsgen.CsvAlarm{{Number: 1, TagName: "tabIB_0", BitNr: 2, Texts: tagsgen.LangTexts{"de-DE": "Start"}}}
+	jsonDir := diffSide(t, tags, alarms)
+
+	// katalog z plikami wejściowymi - projekt generowany jak przez generator
+	inputDir := t.TempDir()
+	symbols := "126," + "Start                   " + " I 10.2 BOOL Start\r\n"
+	if err := os.WriteFile(filepath.Join(inputDir, "Symbols.asc"), []byte(symbols), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		tags   int
+		alarms int
+	}{
+		{"json folder", jsonDir, 1, 1},
+		{"tags.json", filepath.Join(jsonDir, "tags.json"), 1, 1},
+		{"input folder", inputDir, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTags, gotAlarms, err := loadDiffSide(tt.path, defaultMachine())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(gotTags.Tags) != tt.tags || len(gotAlarms.Alarms) != tt.alarms {
+				t.Fatalf("tags = %+v, alarms = %+v", gotTags.Tags, gotAlarms.Alarms)
+			}
+			if s := gotTags.Tags[0]; s.SymbolName != "Start" || tagsgen.SymbolAddress(s) != "I 10.2" {
+				t.Errorf("tag = %+v", s)
+			}
+		})
+	}
+
+	if _, _, err := loadDiffSide(filepath.Join(jsonDir, "missing"), defaultMachine()); err == nil {
+		t.Error("missing path: no error")
+	}
+	if _, _, err := loadDiffSide(t.TempDir(), defaultMachine()); err == nil {
+		t.Error("empty folder: no error")
+	}
+}
+
+func TestPrintDiff(t *testing.T) {
+
+	analog := &tagsgen.AnalogAlarm{Mode: tagsgen.AnalogAbove}
+	oldAlarms := tagsgen.Alarms{Alarms: []tagsgen.CsvAlarm{
+		{Number: 12, Texts: tagsgen.LangTexts{"de-DE": "Not-Aus"}},
+		{Number: 12, BitNr: -1, Analog: analog, Texts: tagsgen.LangTexts{"de-DE": "Temperatur"}},
+	}}
+	newAlarms := tagsgen.Alarms{Alarms: []tagsgen.CsvAlarm{
+		{Number: 12, BitNr: -1, Analog: analog, Texts: tagsgen.LangTexts{"de-DE": "Temperatur hoch"}},
+	}}
+	oldTags := tagsgen.Tags{Tags: []tagsgen.CsvTag{{SymbolName: "Speed", SymbolPeriph: "MW", SymbolAddressHi: "10", TagName: "tabMB_0"}}}
+	newTags := tagsgen.Tags{Tags: []tagsgen.CsvTag{{SymbolName: "Speed", SymbolPeriph: "MW", SymbolAddressHi: "12", TagName: "tabMB_0"}}}
+
+	var buf bytes.Buffer
+	printDiff(&buf, tagsgen.Compare(oldTags, oldAlarms, newTags, newAlarms))
+
+	want := []string{
+		"Changed addresses (1):",
+		"  ~ Speed                                    MW 10 -> MW 12",
+		"Removed alarms (1):",
+		"  - 12 de-DE=Not-Aus",
+		"Changed alarm texts (1):",
+		"  ~ A12 de-DE=Temperatur",
+		"        de-DE=Temperatur hoch",
+	}
+	if got := strings.TrimRight(buf.String(), "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+
+	buf.Reset()
+	printDiff(&buf, tagsgen.Compare(oldTags, oldAlarms, oldTags, oldAlarms))
+	if buf.String() != "No differences\n" {
+		t.Errorf("same projects: %q", buf.String())
+	}
+}
+
+func TestDiffProjectsOutputFile(t *testing.T) {
+
+	oldDir := diffSide(t, nil, []tagsgen.CsvAlarm{{Number: 1, Texts: tagsgen.LangTexts{"de-DE": "Alt"}}})
+	newDir := diffSide(t, nil, []tagsgen.CsvAlarm{{Number: 1, Texts: tagsgen.LangTexts{"de-DE": "Neu"}}})
+	out := filepath.Join(t.TempDir(), "diff.json")
+
+	if err := diffProjects(oldDir, newDir, defaultMachine(), out, true); err != nil {
+		t.Fatal(err)
+	}
+	var d tagsgen.Diff
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.ChangedAlarmTexts) != 1 || d.ChangedAlarmTexts[0].NewTexts["de-DE"] != "Neu" {
+		t.Errorf("diff = %+v", d)
+	}
+
+	// błąd wczytania zwracany z nazwą ścieżki, plik wyjściowy nie jest tworzony
+	missing := filepath.Join(oldDir, "missing")
+	out = filepath.Join(t.TempDir(), "diff.txt")
+	if err := diffProjects(missing, newDir, defaultMachine(), out, false); err == nil || !strings.HasPrefix(err.Error(), missing) {
+		t.Errorf("missing old project: %v", err)
+	}
+	if fileExists(out) {
+		t.Error("output file created after error")
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/dkt64/tagsgenerator/pkg/alarms"
+	"github.com/dkt64/tagsgenerator/pkg/decoder"
+)
+
+// symbolTopic - temat MQTT dla wartości symbolu (bez znaków zastrzeżonych + i #)
+// ================================================================================================
+func symbolTopic(prefix string, symbol string) string {
+	return prefix + "/" + strings.NewReplacer("+", "_", "#", "_").Replace(symbol)
+}
+
+// listener - dekodowanie komunikatów IoT Gateway i wykrywanie zmian wartości symboli
+// ================================================================================================
+type listener struct {
+	dec       *decoder.Decoder
+	engine    *alarms.Engine
+	history   *alarms.History
+	client    mqtt.Client
+	publish   string
+	all       bool
+	mu        sync.Mutex
+	lastValue map[string]string
+}
+
+// onMessage - obsługa komunikatu IoT Gateway
+// ================================================================================================
+func (l *listener) onMessage(_ mqtt.Client, msg mqtt.Message) {
+
+	payload, err := decoder.ParsePayload(msg.Payload())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s: %v\n", msg.Topic(), err)
+		return
+	}
+
+	values, _, err := l.dec.DecodeValues(payload.Values)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s: %v\n", msg.Topic(), err)
+	}
+
+	stamp := time.Now()
+	if payload.Timestamp > 0 {
+		stamp = time.Unix(0, payload.Timestamp*int64(time.Millisecond))
+	}
+
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.engine != nil {
+		l.alarmEvents(l.engine.UpdateValues(l.dec, payload.Values))
+	}
+
+	for _, name := range names {
+		text := decoder.FormatValue(values[name])
+		last, seen := l.lastValue[name]
+		changed := !seen || last != text
+		l.lastValue[name] = text
+
+		if !changed && !l.all {
+			continue
+		}
+
+		if seen && changed {
+			fmt.Printf("%s %s: %s -> %s\n", stamp.Format("2006-01-02 15:04:05.000"), name, last, text)
+		} else {
+			fmt.Printf("%s %s = %s\n", stamp.Format("2006-01-02 15:04:05.000"), name, text)
+		}
+
+		if l.publish != "" {
+			data, err := json.Marshal(map[string]interface{}{"v": values[name], "t": stamp.UnixNano() / int64(time.Millisecond)})
+			if err == nil {
+				l.client.Publish(symbolTopic(l.publish, name), 0, false, data)
+			}
+		}
+	}
+}
+
+// alarmEvents - wypisanie zdarzeń alarmów i zapis do historii
+// ================================================================================================
+func (l *listener) alarmEvents(events []alarms.Event) {
+	for _, ev := range events {
+		// pierwszy odczyt nieaktywnego alarmu nie jest zmianą stanu
+		if ev.First && ev.Type == alarms.EventCleared {
+			continue
+		}
+		fmt.Printf("%s ALARM %s %s: %s\n", ev.Time.Format("2006-01-02 15:04:05.000"), alarms.FormatNumber(ev.Number, ev.Analog), ev.Type, ev.Text)
+	}
+	if l.history != nil && len(events) > 0 {
+		if err := l.history.Record(events...); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", err)
+		}
+	}
+}
+
+// onAck - potwierdzenie alarmu komunikatem MQTT: numer alarmu ("12", "A12") lub "all"
+// ================================================================================================
+func (l *listener) onAck(_ mqtt.Client, msg mqtt.Message) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	text := strings.TrimSpace(string(msg.Payload()))
+	if strings.EqualFold(text, "all") {
+		l.alarmEvents(l.engine.AcknowledgeAll(time.Now()))
+		return
+	}
+
+	number, analog, err := alarms.ParseNumber(text)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s: %v\n", msg.Topic(), err)
+		return
+	}
+	ev, err := l.engine.Acknowledge(number, analog, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s: %v\n", msg.Topic(), err)
+		return
+	}
+	l.alarmEvents([]alarms.Event{ev})
+}
+
+// runListen - polecenie listen: subskrypcja komunikatów IoT Gateway i dekodowanie bloków
+// tagsgenerator listen [-broker tcp://localhost:1883] [-topic iotgateway] [-tags tags.json]
+// [-alarms alarms.json] [-history alarms.db] [-ack-topic alarms/ack]
+// ================================================================================================
+func runListen(args []string) {
+
+	fs := flag.NewFlagSet("listen", flag.ExitOnError)
+	broker := fs.String("broker", "tcp://localhost:1883", "MQTT broker address")
+	topic := fs.String("topic", "iotgateway", "IoT Gateway MQTT topic")
+	tagsFilename := fs.String("tags", "tags.json", "Tags description file (input)")
+	alarmsFilename := fs.String("alarms", "", "Alarms description file (input), e.g. alarms.json - print alarm events")
+	lang := fs.String("lang", "", "Language of alarm texts, e.g. de-DE (default: first text)")
+	clientID := fs.String("id", "tagsgenerator", "MQTT client ID")
+	user := fs.String("user", "", "MQTT user name")
+	password := fs.String("password", "", "MQTT password")
+	publish := fs.String("publish", "", "Re-publish symbol values under this topic prefix (<prefix>/<symbol>)")
+	all := fs.Bool("all", false, "Print every received value, not only changes")
+	historyFilename := fs.String("history", "", "Record alarm events to this alarm history database, e.g. alarms.db (requires -alarms)")
+	ackTopic := fs.String("ack-topic", "", "Acknowledge alarms by messages on this MQTT topic: alarm number (12, A12 for analog alarms) or all (requires -alarms)")
+	fs.Parse(args)
+
+	dec, err := decoder.Load(*tagsFilename, *alarmsFilename)
+	if err != nil {
+		fail(exitError, err)
+	}
+
+	l := &listener{dec: dec, publish: *publish, all: *all, lastValue: make(map[string]string)}
+	if *alarmsFilename != "" {
+		l.engine = alarms.NewEngine(dec.Alarms, *lang)
+	}
+	if *historyFilename != "" {
+		if l.engine == nil {
+			fail(exitUsage, errors.New("-history requires -alarms"))
+		}
+		if l.history, err = alarms.OpenHistory(*historyFilename); err != nil {
+			fail(exitError, err)
+		}
+		defer l.history.Close()
+	}
+	if *ackTopic != "" && l.engine == nil {
+		fail(exitUsage, errors.New("-ack-topic requires -alarms"))
+	}
+
+	opts := mqtt.NewClientOptions().AddBroker(*broker).SetClientID(*clientID).SetAutoReconnect(true)
+	if *user != "" {
+		opts.SetUsername(*user).SetPassword(*password)
+	}
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		// ponowna subskrypcja po odzyskaniu połączenia
+		if t := c.Subscribe(*topic, 0, l.onMessage); t.Wait() && t.Error() != nil {
+			fmt.Fprintln(os.Stderr, "ERROR:", t.Error())
+		}
+		if *ackTopic != "" {
+			if t := c.Subscribe(*ackTopic, 1, l.onAck); t.Wait() && t.Error() != nil {
+				fmt.Fprintln(os.Stderr, "ERROR:", t.Error())
+			}
+		}
+	})
+
+	l.client = mqtt.NewClient(opts)
+	if t := l.client.Connect(); t.Wait() && t.Error() != nil {
+		fail(exitError, t.Error())
+	}
+	defer l.client.Disconnect(250)
+
+	fmt.Printf("Listening on %s, topic %s ...\n", *broker, *topic)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+}
@@ -0,0 +1,445 @@
+// Package alarms ocenia stany alarmów HMI z alarms.json na podstawie wartości tagów IoT Gateway:
+// zbocza bitów wyzwalających, stany aktywny / zniknął / potwierdzony i znaczniki czasu.
+package alarms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dkt64/tagsgenerator/pkg/decoder"
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// Stany alarmu
+// ================================================================================================
+const (
+	StateInactive     = "inactive"     // nieaktywny i potwierdzony (lub nigdy nie wystąpił)
+	StateActive       = "active"       // przyszedł, niepotwierdzony
+	StateAcknowledged = "acknowledged" // przyszedł, potwierdzony
+	StateCleared      = "cleared"      // zniknął, czeka na potwierdzenie
+)
+
+// Rodzaje zdarzeń alarmu
+// ================================================================================================
+const (
+	EventActivated    = "activated"
+	EventCleared      = "cleared"
+	EventAcknowledged = "acknowledged"
+)
+
+// ErrUnknownAlarm - brak alarmu o podanym numerze
+// ================================================================================================
+var ErrUnknownAlarm = errors.New("unknown alarm")
+
+// alarmID - klucz alarmu: TIA Portal numeruje alarmy bitowe i analogowe osobno, więc ten sam
+// numer może mieć alarm bitowy i analogowy
+// ================================================================================================
+type alarmID struct {
+	number int
+	analog bool
+}
+
+// Alarm - stan jednego alarmu
+// ================================================================================================
+type Alarm struct {
+	Number  int
+	Analog  bool `json:",omitempty"`
+	Text    string
+	TagName string
+	Index   int
+	BitNr   int
+	Class   string `json:",omitempty"`
+	Group   string `json:",omitempty"`
+
+	Active       bool
+	Acknowledged bool
+
+	ActivatedAt    time.Time
+	ClearedAt      time.Time
+	AcknowledgedAt time.Time
+
+	texts  tagsgen.LangTexts
+	analog *tagsgen.AnalogAlarm
+
+	// acks - bity potwierdzenia z HMI i sterownika (AckHMI, AckPLC); ackOn, ackSeen - ich ostatnie
+	// stany i czy stan był już odczytany (pierwszy odczyt nie jest zboczem)
+	acks    []*tagsgen.AlarmPointer
+	ackOn   []bool
+	ackSeen []bool
+
+	// observed - czy stan alarmu był już odczytany (Event.First)
+	observed bool
+}
+
+// State - stan alarmu
+// ================================================================================================
+func (a *Alarm) State() string {
+	switch {
+	case a.Active && a.Acknowledged:
+		return StateAcknowledged
+	case a.Active:
+		return StateActive
+	case !a.ActivatedAt.IsZero() && !a.Acknowledged:
+		return StateCleared
+	}
+	return StateInactive
+}
+
+// Event - zdarzenie alarmu: przyszedł, zniknął, potwierdzony
+// ================================================================================================
+type Event struct {
+	Number int
+	Analog bool `json:",omitempty"`
+	Type   string
+	Time   time.Time
+	Text   string
+	State  string
+
+	// Texts - teksty alarmu we wszystkich językach (język -> tekst)
+	Texts tagsgen.LangTexts `json:",omitempty"`
+
+	// First - pierwszy odczyt stanu alarmu po uruchomieniu silnika: activated - alarm mógł trwać
+	// już wcześniej, cleared - alarm jest nieaktywny (bez zbocza, zamyka w historii wystąpienie
+	// otwarte przed uruchomieniem)
+	First bool `json:",omitempty"`
+}
+
+// Text - tekst alarmu w języku lang ("de-DE"); pierwszy niepusty tekst, gdy brak tekstu w danym
+// języku
+// ================================================================================================
+func Text(texts tagsgen.LangTexts, lang string) string {
+	return texts.Text(lang)
+}
+
+// Engine - stany alarmów jednego projektu
+// ================================================================================================
+type Engine struct {
+	Language string
+
+	mu     sync.Mutex
+	alarms []*Alarm
+	byID   map[alarmID]*Alarm
+	byTag  map[string][]*Alarm
+
+	// blocks, values - ostatnie dane bloków i wartości tagów z typem danych; wartość i granica
+	// alarmu analogowego mogą przyjść w różnych komunikatach
+	blocks map[string][]byte
+	values map[string]interface{}
+}
+
+// NewEngine - stany alarmów z alarms.json; teksty zdarzeń w języku lang
+// ================================================================================================
+func NewEngine(defs tagsgen.Alarms, lang string) *Engine {
+
+	e := &Engine{Language: lang, byID: make(map[alarmID]*Alarm), byTag: make(map[string][]*Alarm), blocks: make(map[string][]byte), values: make(map[string]interface{})}
+	for _, def := range defs.Alarms {
+		id := alarmID{number: def.Number, analog: def.Analog != nil}
+		if _, ok := e.byID[id]; ok {
+			continue
+		}
+		a := &Alarm{
+			Number:  def.Number,
+			Analog:  id.analog,
+			Text:    Text(def.Texts, lang),
+			TagName: def.TagName,
+			Index:   def.Index,
+			BitNr:   def.BitNr,
+			Class:   def.Class,
+			Group:   def.Group,
+			texts:   def.Texts,
+			analog:  def.Analog,
+		}
+		for _, ack := range []*tagsgen.AlarmPointer{def.AckHMI, def.AckPLC} {
+			if ack != nil {
+				a.acks = append(a.acks, ack)
+				a.ackOn = append(a.ackOn, false)
+				a.ackSeen = append(a.ackSeen, false)
+			}
+		}
+		e.alarms = append(e.alarms, a)
+		e.byID[id] = a
+
+		// alarm jest oceniany przy zmianie taga wyzwalającego, taga granicy i tagów potwierdzenia
+		tagNames := []string{a.TagName}
+		if l := def.Analog; l != nil && l.LimitTag != nil {
+			tagNames = append(tagNames, l.LimitTag.TagName)
+		}
+		for _, ack := range a.acks {
+			tagNames = append(tagNames, ack.TagName)
+		}
+		indexed := make(map[string]bool)
+		for _, tagName := range tagNames {
+			if !indexed[tagName] {
+				indexed[tagName] = true
+				e.byTag[tagName] = append(e.byTag[tagName], a)
+			}
+		}
+	}
+	return e
+}
+
+// event - zdarzenie alarmu w języku silnika
+// ================================================================================================
+func (e *Engine) event(a *Alarm, typ string, t time.Time) Event {
+	return Event{Number: a.Number, Analog: a.Analog, Type: typ, Time: t, Text: Text(a.texts, e.Language), State: a.State(), Texts: a.texts}
+}
+
+// set - nowy stan bitu wyzwalającego; zdarzenie przy zboczu
+// ================================================================================================
+func (e *Engine) set(a *Alarm, on bool, t time.Time) (Event, bool) {
+
+	if on == a.Active {
+		return Event{}, false
+	}
+
+	a.Active = on
+	if on {
+		a.ActivatedAt = t
+		a.ClearedAt = time.Time{}
+		a.Acknowledged = false
+		a.AcknowledgedAt = time.Time{}
+		return e.event(a, EventActivated, t), true
+	}
+
+	a.ClearedAt = t
+	return e.event(a, EventCleared, t), true
+}
+
+// evaluate - stan alarmu z ostatnich danych bloków i wartości tagów z typem danych
+// ================================================================================================
+func (e *Engine) evaluate(a *Alarm) (bool, error) {
+
+	def := tagsgen.CsvAlarm{Number: a.Number, TagName: a.TagName, Index: a.Index, BitNr: a.BitNr, Analog: a.analog}
+	if a.analog != nil {
+		return decoder.AnalogAlarmActive(def, e.blocks, e.values, a.Active)
+	}
+	return e.bit(a.TagName, a.Index, a.BitNr)
+}
+
+// bit - stan bitu w ostatnich danych bloku lub w wartości taga z typem danych
+// ================================================================================================
+func (e *Engine) bit(tagName string, index int, bitNr int) (bool, error) {
+
+	if _, ok := e.blocks[tagName]; ok {
+		return decoder.AlarmActive(tagsgen.CsvAlarm{TagName: tagName, Index: index, BitNr: bitNr}, e.blocks)
+	}
+	value, ok := e.values[tagName]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", decoder.ErrNoValue, tagName)
+	}
+	return tagBit(value, index, bitNr)
+}
+
+// tagBit - bit w wartości taga z typem danych (liczba lub tablica liczb z komunikatu IoT Gateway);
+// dla tablic index to numer elementu
+// ================================================================================================
+func tagBit(value interface{}, index int, bitNr int) (bool, error) {
+
+	if arr, ok := value.([]interface{}); ok {
+		if index >= len(arr) {
+			return false, fmt.Errorf("%w: element %d of %d", decoder.ErrShortBlock, index, len(arr))
+		}
+		value = arr[index]
+	}
+	n, err := decoder.Number(value)
+	if err != nil {
+		return false, err
+	}
+	return int64(n)&(1<<uint(bitNr)) != 0, nil
+}
+
+// update - ocena alarmów, których tag wyzwalający, tag granicy lub tag potwierdzenia zmienił się
+// ================================================================================================
+func (e *Engine) update(tagNames []string, t time.Time) (events []Event) {
+
+	seen := make(map[*Alarm]bool)
+	for _, tagName := range tagNames {
+		for _, a := range e.byTag[tagName] {
+			if seen[a] {
+				continue
+			}
+			seen[a] = true
+
+			if on, err := e.evaluate(a); err == nil {
+				first := !a.observed
+				a.observed = true
+				if ev, ok := e.set(a, on, t); ok {
+					ev.First = first
+					events = append(events, ev)
+				} else if first {
+					ev := e.event(a, EventCleared, t)
+					ev.First = true
+					events = append(events, ev)
+				}
+			}
+
+			// zbocze bitu potwierdzenia z HMI lub sterownika potwierdza alarm
+			for i, ack := range a.acks {
+				on, err := e.bit(ack.TagName, ack.Index, ack.BitNr)
+				if err != nil {
+					continue
+				}
+				rising := on && !a.ackOn[i] && a.ackSeen[i]
+				a.ackOn[i], a.ackSeen[i] = on, true
+				if !rising {
+					continue
+				}
+				if ev, ok := e.acknowledge(a, t); ok {
+					events = append(events, ev)
+				}
+			}
+		}
+	}
+
+	sortEvents(events)
+	return
+}
+
+// UpdateBlocks - ocena bitów wyzwalających i wartości alarmów analogowych w blokach (nazwa taga ->
+// bajty); alarmy, których bloku nie ma w blocks, nie zmieniają stanu.
+// ================================================================================================
+func (e *Engine) UpdateBlocks(blocks map[string][]byte, t time.Time) []Event {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var tagNames []string
+	for tagName, data := range blocks {
+		e.blocks[tagName] = data
+		tagNames = append(tagNames, tagName)
+	}
+	return e.update(tagNames, t)
+}
+
+// UpdateTag - ocena alarmów w wartości taga z typem danych (liczba lub tablica liczb z komunikatu
+// IoT Gateway): bity wyzwalające (dla tablic Index to numer elementu) oraz wartości i granice
+// alarmów analogowych
+// ================================================================================================
+func (e *Engine) UpdateTag(tagName string, value interface{}, t time.Time) []Event {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.values[tagName] = value
+	return e.update([]string{tagName}, t)
+}
+
+// UpdateValues - ocena alarmów na podstawie wartości tagów z komunikatu IoT Gateway
+// ================================================================================================
+func (e *Engine) UpdateValues(dec *decoder.Decoder, values []decoder.IotValue) (events []Event) {
+
+	for _, v := range values {
+		if !v.Quality {
+			continue
+		}
+
+		t := time.Now()
+		if v.Timestamp > 0 {
+			t = time.Unix(0, v.Timestamp*int64(time.Millisecond))
+		}
+
+		tagName := dec.TagName(v.ID)
+		if data, err := v.Bytes(); err == nil && dec.IsBlock(tagName) {
+			events = append(events, e.UpdateBlocks(map[string][]byte{tagName: data}, t)...)
+			continue
+		}
+
+		var value interface{}
+		if err := json.Unmarshal(v.V, &value); err == nil {
+			events = append(events, e.UpdateTag(tagName, value, t)...)
+		}
+	}
+
+	sortEvents(events)
+	return
+}
+
+// Acknowledge - potwierdzenie alarmu bitowego (analog = false) lub analogowego o danym numerze
+// ================================================================================================
+func (e *Engine) Acknowledge(number int, analog bool, t time.Time) (Event, error) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	a, ok := e.byID[alarmID{number: number, analog: analog}]
+	if !ok {
+		return Event{}, fmt.Errorf("%w: %d", ErrUnknownAlarm, number)
+	}
+	ev, ok := e.acknowledge(a, t)
+	if !ok {
+		return Event{}, fmt.Errorf("alarm %d is not waiting for acknowledgement", number)
+	}
+	return ev, nil
+}
+
+// acknowledge - potwierdzenie alarmu czekającego na potwierdzenie
+// ================================================================================================
+func (e *Engine) acknowledge(a *Alarm, t time.Time) (Event, bool) {
+
+	if a.Acknowledged || a.ActivatedAt.IsZero() {
+		return Event{}, false
+	}
+	a.Acknowledged = true
+	a.AcknowledgedAt = t
+	return e.event(a, EventAcknowledged, t), true
+}
+
+// AcknowledgeAll - potwierdzenie wszystkich niepotwierdzonych alarmów
+// ================================================================================================
+func (e *Engine) AcknowledgeAll(t time.Time) (events []Event) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, a := range e.alarms {
+		if ev, ok := e.acknowledge(a, t); ok {
+			events = append(events, ev)
+		}
+	}
+	return
+}
+
+// Alarms - kopia stanów wszystkich alarmów
+// ================================================================================================
+func (e *Engine) Alarms() []Alarm {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := make([]Alarm, len(e.alarms))
+	for i, a := range e.alarms {
+		list[i] = *a
+		list[i].Text = Text(a.texts, e.Language)
+	}
+	return list
+}
+
+// Active - alarmy aktywne lub czekające na potwierdzenie (widok alarmów HMI)
+// ================================================================================================
+func (e *Engine) Active() (list []Alarm) {
+	for _, a := range e.Alarms() {
+		if a.State() != StateInactive {
+			list = append(list, a)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ActivatedAt.After(list[j].ActivatedAt) })
+	return
+}
+
+// sortEvents - zdarzenia w kolejności czasu i numeru alarmu
+// ================================================================================================
+func sortEvents(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Time.Equal(events[j].Time) {
+			if events[i].Number == events[j].Number {
+				return !events[i].Analog && events[j].Analog
+			}
+			return events[i].Number < events[j].Number
+		}
+		return events[i].Time.Before(events[j].Time)
+	})
+}
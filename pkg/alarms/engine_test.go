@@ -0,0 +1,155 @@
+package alarms
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// eventTypes - rodzaje zdarzeń
+// ================================================================================================
+func eventTypes(events []Event) (types []string) {
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	return
+}
+
+func TestAnalogAlarmWithLimitTag(t *testing.T) {
+
+	defs := tagsgen.Alarms{Alarms: []tagsgen.CsvAlarm{{
+		Number:  7,
+		TagName: "Temperature",
+		BitNr:   -1,
+		Analog: &tagsgen.AnalogAlarm{
+			Trigger:  tagsgen.ValuePointer{Tag: "Temperature", TagName: "Temperature", SymbolDataType: "REAL", DataType: "Float", Size: 4},
+			Mode:     tagsgen.AnalogAbove,
+			LimitTag: &tagsgen.ValuePointer{Tag: "TempLimit", TagName: "TempLimit", SymbolDataType: "INT", DataType: "Short", Size: 2},
+		},
+	}}}
+	e := NewEngine(defs, "")
+	now := time.Now()
+
+	steps := []struct {
+		tag   string
+		value float64
+		want  []string
+	}{
+		{"Temperature", 50, nil}, // granica jeszcze nieznana
+		{"TempLimit", 40, []string{EventActivated}},
+		{"Temperature", 45, nil},
+		{"TempLimit", 60, []string{EventCleared}},
+		{"Temperature", 61, []string{EventActivated}},
+	}
+	for i, s := range steps {
+		got := eventTypes(e.UpdateTag(s.tag, s.value, now))
+		if len(got) != len(s.want) || (len(got) > 0 && got[0] != s.want[0]) {
+			t.Errorf("step %d: %s = %g: events %v, want %v", i, s.tag, s.value, got, s.want)
+		}
+	}
+}
+
+func TestAnalogAlarmLimitInOtherBlock(t *testing.T) {
+
+	defs := tagsgen.Alarms{Alarms: []tagsgen.CsvAlarm{{
+		Number:  1,
+		TagName: "tabMB_20",
+		BitNr:   -1,
+		Analog: &tagsgen.AnalogAlarm{
+			Trigger:  tagsgen.ValuePointer{Tag: "Level", TagName: "tabMB_20", SymbolDataType: "INT", DataType: "Byte Array", Size: 2},
+			Mode:     tagsgen.AnalogBelow,
+			LimitTag: &tagsgen.ValuePointer{Tag: "MinLevel", TagName: "tabMB_40", SymbolDataType: "INT", DataType: "Byte Array", Size: 2},
+		},
+	}}}
+	e := NewEngine(defs, "")
+	now := time.Now()
+
+	if ev := e.UpdateBlocks(map[string][]byte{"tabMB_20": {0, 5}}, now); len(ev) != 0 {
+		t.Errorf("events without limit: %v", eventTypes(ev))
+	}
+	if ev := e.UpdateBlocks(map[string][]byte{"tabMB_40": {0, 10}}, now); len(ev) != 1 || ev[0].Type != EventActivated {
+		t.Errorf("events after limit: %v", eventTypes(ev))
+	}
+}
+
+func TestDiscreteAndAnalogAlarmWithSameNumber(t *testing.T) {
+
+	defs := tagsgen.Alarms{Alarms: []tagsgen.CsvAlarm{
+		{Number: 1, TagName: "tabMB_0", Index: 1, BitNr: 0},
+		{Number: 1, TagName: "Level", BitNr: -1, Analog: &tagsgen.AnalogAlarm{
+			Trigger: tagsgen.ValuePointer{Tag: "Level", TagName: "Level", DataType: "Short"},
+			Mode:    tagsgen.AnalogAbove,
+			Limit:   10,
+		}},
+	}}
+	e := NewEngine(defs, "")
+	now := time.Now()
+
+	if n := len(e.Alarms()); n != 2 {
+		t.Fatalf("%d alarms, want 2", n)
+	}
+	if ev := e.UpdateBlocks(map[string][]byte{"tabMB_0": {0, 1}}, now); len(ev) != 1 || ev[0].Analog {
+		t.Errorf("discrete events: %+v", ev)
+	}
+	if ev := e.UpdateTag("Level", 11.0, now); len(ev) != 1 || !ev[0].Analog {
+		t.Errorf("analog events: %+v", ev)
+	}
+	if _, err := e.Acknowledge(1, true, now); err != nil {
+		t.Error(err)
+	}
+	for _, a := range e.Alarms() {
+		if a.Acknowledged != a.Analog {
+			t.Errorf("alarm %d (analog %v): acknowledged %v", a.Number, a.Analog, a.Acknowledged)
+		}
+	}
+}
+
+func TestAcknowledgeByPLCBit(t *testing.T) {
+
+	defs := tagsgen.Alarms{Alarms: []tagsgen.CsvAlarm{{
+		Number:  3,
+		TagName: "tabMB_0",
+		Index:   1,
+		BitNr:   0,
+		AckPLC:  &tagsgen.AlarmPointer{Tag: "AckPLC", TagName: "tabMB_8", Index: 0, BitNr: 2},
+	}}}
+	e := NewEngine(defs, "")
+	now := time.Now()
+
+	if ev := e.UpdateBlocks(map[string][]byte{"tabMB_0": {0, 1}, "tabMB_8": {0x04}}, now); len(ev) != 1 || ev[0].Type != EventActivated {
+		t.Fatalf("events: %v", eventTypes(ev))
+	}
+
+	// bit potwierdzenia już ustawiony - potwierdza tylko zbocze narastające
+	if ev := e.UpdateBlocks(map[string][]byte{"tabMB_8": {0x00}}, now); len(ev) != 0 {
+		t.Errorf("events on falling ack bit: %v", eventTypes(ev))
+	}
+	ev := e.UpdateBlocks(map[string][]byte{"tabMB_8": {0x04}}, now)
+	if len(ev) != 1 || ev[0].Type != EventAcknowledged {
+		t.Fatalf("events on rising ack bit: %v", eventTypes(ev))
+	}
+	if a := e.Alarms()[0]; a.State() != StateAcknowledged {
+		t.Errorf("state = %s", a.State())
+	}
+}
+
+func TestEngineFirstObservation(t *testing.T) {
+
+	defs := tagsgen.Alarms{Alarms: []tagsgen.CsvAlarm{
+		{Number: 1, TagName: "tabMB_0", Index: 1, BitNr: 0},
+		{Number: 2, TagName: "tabMB_0", Index: 1, BitNr: 1},
+	}}
+	e := NewEngine(defs, "")
+	now := time.Now()
+
+	// pierwszy odczyt: alarm 1 aktywny, alarm 2 nieaktywny
+	ev := e.UpdateBlocks(map[string][]byte{"tabMB_0": {0, 1}}, now)
+	if len(ev) != 2 || ev[0].Type != EventActivated || !ev[0].First || ev[1].Type != EventCleared || !ev[1].First {
+		t.Fatalf("first events: %+v", ev)
+	}
+	ev = e.UpdateBlocks(map[string][]byte{"tabMB_0": {0, 2}}, now)
+	if len(ev) != 2 || ev[0].First || ev[1].First {
+		t.Errorf("later events: %+v", ev)
+	}
+}
@@ -0,0 +1,319 @@
+package alarms
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// Kubełki bazy historii alarmów
+// ================================================================================================
+var (
+	bucketRecords = []byte("records") // id -> Record (json)
+	bucketLatest  = []byte("latest")  // klucz alarmu (latestKey) -> id ostatniego wystąpienia
+)
+
+// Record - jedno wystąpienie alarmu: przyszedł, zniknął, potwierdzony
+// ================================================================================================
+type Record struct {
+	ID     uint64
+	Number int
+	Analog bool `json:",omitempty"`
+	Texts  tagsgen.LangTexts
+
+	Came  time.Time
+	Went  time.Time `json:",omitempty"`
+	Acked time.Time `json:",omitempty"`
+}
+
+// Duration - czas trwania alarmu (do teraz, gdy alarm jest aktywny)
+// ================================================================================================
+func (r *Record) Duration(now time.Time) time.Duration {
+	if r.Went.IsZero() {
+		return now.Sub(r.Came)
+	}
+	return r.Went.Sub(r.Came)
+}
+
+// Query - kryteria wyszukiwania w historii; zerowe pola oznaczają brak ograniczenia
+// ================================================================================================
+type Query struct {
+	From   time.Time
+	To     time.Time
+	Number int
+
+	// Analog - tylko alarmy analogowe (true) lub bitowe (false); nil - oba rodzaje
+	Analog *bool
+}
+
+// matches - czy wystąpienie alarmu pokrywa się z zakresem czasu i ma podany numer i rodzaj
+// ================================================================================================
+func (q Query) matches(r *Record) bool {
+	if q.Number != 0 && r.Number != q.Number {
+		return false
+	}
+	if q.Analog != nil && r.Analog != *q.Analog {
+		return false
+	}
+	if !q.To.IsZero() && !r.Came.Before(q.To) {
+		return false
+	}
+	if !q.From.IsZero() && !r.Went.IsZero() && r.Went.Before(q.From) {
+		return false
+	}
+	return true
+}
+
+// History - historia alarmów w pliku bazy bbolt
+// ================================================================================================
+type History struct {
+	db *bolt.DB
+}
+
+// OpenHistory - otwarcie (utworzenie) pliku historii alarmów
+// ================================================================================================
+func OpenHistory(filename string) (*History, error) {
+
+	db, err := bolt.Open(filename, 0666, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketRecords, bucketLatest} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &History{db: db}, nil
+}
+
+// Close - zamknięcie pliku historii
+// ================================================================================================
+func (h *History) Close() error {
+	return h.db.Close()
+}
+
+// itob - klucz bazy z liczby
+// ================================================================================================
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// latestKey - klucz ostatniego wystąpienia alarmu: numer, dla alarmów analogowych z przedrostkiem
+// "A" (numery alarmów bitowych i analogowych mogą się powtarzać)
+// ================================================================================================
+func latestKey(number int, analog bool) []byte {
+	if analog {
+		return append([]byte("A"), itob(uint64(number))...)
+	}
+	return itob(uint64(number))
+}
+
+// Record - zapis zdarzenia alarmu: activated otwiera nowe wystąpienie, cleared i acknowledged
+// uzupełniają ostatnie wystąpienie alarmu. Otwarte wystąpienie sprzed ponownego uruchomienia jest
+// kontynuowane tylko przy pierwszym odczycie aktywnego alarmu (Event.First); pierwszy odczyt
+// nieaktywnego alarmu albo nowe przyjście zamykają je.
+// ================================================================================================
+func (h *History) Record(events ...Event) error {
+
+	return h.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(bucketRecords)
+		latest := tx.Bucket(bucketLatest)
+
+		for _, ev := range events {
+			nr := latestKey(ev.Number, ev.Analog)
+
+			var r Record
+			if ev.Type == EventActivated {
+				if id := latest.Get(nr); id != nil {
+					var open Record
+					if err := json.Unmarshal(records.Get(id), &open); err != nil {
+						return err
+					}
+					if open.Went.IsZero() {
+						// alarm aktywny po ponownym uruchomieniu listen - to samo wystąpienie
+						if ev.First {
+							continue
+						}
+						// wystąpienie niezamknięte przed zatrzymaniem - kończy się z nowym przyjściem
+						open.Went = ev.Time
+						data, err := json.Marshal(open)
+						if err != nil {
+							return err
+						}
+						if err := records.Put(id, data); err != nil {
+							return err
+						}
+					}
+				}
+
+				id, err := records.NextSequence()
+				if err != nil {
+					return err
+				}
+				r = Record{ID: id, Number: ev.Number, Analog: ev.Analog, Texts: ev.Texts, Came: ev.Time}
+			} else {
+				id := latest.Get(nr)
+				if id == nil {
+					continue
+				}
+				if err := json.Unmarshal(records.Get(id), &r); err != nil {
+					return err
+				}
+				switch ev.Type {
+				case EventCleared:
+					if !r.Went.IsZero() {
+						continue
+					}
+					r.Went = ev.Time
+				case EventAcknowledged:
+					if !r.Acked.IsZero() {
+						continue
+					}
+					r.Acked = ev.Time
+				}
+			}
+
+			data, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			if err := records.Put(itob(r.ID), data); err != nil {
+				return err
+			}
+			if err := latest.Put(nr, itob(r.ID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Query - wystąpienia alarmów w kolejności czasu przyjścia
+// ================================================================================================
+func (h *History) Query(q Query) (list []Record, err error) {
+
+	err = h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRecords).ForEach(func(_, v []byte) error {
+			var r Record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			if q.matches(&r) {
+				list = append(list, r)
+			}
+			return nil
+		})
+	})
+
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Came.Before(list[j].Came) })
+	return
+}
+
+// Summary - liczba wystąpień i łączny czas trwania jednego alarmu
+// ================================================================================================
+type Summary struct {
+	Number   int
+	Analog   bool `json:",omitempty"`
+	Text     string
+	Count    int
+	Duration time.Duration
+}
+
+// Summarize - podsumowanie wystąpień wg numeru alarmu, od najdłuższego czasu trwania
+// ================================================================================================
+func Summarize(records []Record, lang string, now time.Time) (list []Summary) {
+
+	byID := make(map[alarmID]int)
+	for _, r := range records {
+		id := alarmID{number: r.Number, analog: r.Analog}
+		i, ok := byID[id]
+		if !ok {
+			list = append(list, Summary{Number: r.Number, Analog: r.Analog, Text: Text(r.Texts, lang)})
+			i = len(list) - 1
+			byID[id] = i
+		}
+		list[i].Count++
+		list[i].Duration += r.Duration(now)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Duration > list[j].Duration })
+	return
+}
+
+// FormatNumber - numer alarmu w raportach; alarmy analogowe z przedrostkiem "A" ("A12")
+// ================================================================================================
+func FormatNumber(number int, analog bool) string {
+	if analog {
+		return "A" + strconv.Itoa(number)
+	}
+	return strconv.Itoa(number)
+}
+
+// ParseNumber - numer alarmu w postaci FormatNumber ("12", "A12")
+// ================================================================================================
+func ParseNumber(s string) (number int, analog bool, err error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "A") || strings.HasPrefix(s, "a") {
+		analog, s = true, s[1:]
+	}
+	if number, err = strconv.Atoi(s); err != nil {
+		return 0, false, fmt.Errorf("invalid alarm number %q", s)
+	}
+	return
+}
+
+// formatTime - czas w raportach; pusty dla zerowego czasu
+// ================================================================================================
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05.000")
+}
+
+// WriteCSV - eksport wystąpień alarmów do CSV z tekstami w języku lang
+// ================================================================================================
+func WriteCSV(w io.Writer, records []Record, lang string) error {
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"Number", "Came", "Went", "Acknowledged", "Duration [s]", "Text"})
+
+	for _, r := range records {
+		duration := ""
+		if !r.Went.IsZero() {
+			duration = strconv.FormatFloat(r.Duration(r.Went).Seconds(), 'f', 3, 64)
+		}
+		out.Write([]string{
+			FormatNumber(r.Number, r.Analog),
+			formatTime(r.Came),
+			formatTime(r.Went),
+			formatTime(r.Acked),
+			duration,
+			Text(r.Texts, lang),
+		})
+	}
+
+	out.Flush()
+	return out.Error()
+}
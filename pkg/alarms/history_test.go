@@ -0,0 +1,175 @@
+package alarms
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryRestartWhileActive(t *testing.T) {
+
+	h, err := OpenHistory(filepath.Join(t.TempDir(), "alarms.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	came := time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)
+	restart := came.Add(time.Hour)
+	went := came.Add(2 * time.Hour)
+
+	events := []Event{
+		{Number: 12, Type: EventActivated, Time: came},
+		{Number: 12, Type: EventActivated, Time: restart, First: true}, // listen uruchomiony ponownie, alarm nadal aktywny
+		{Number: 12, Type: EventAcknowledged, Time: restart},
+		{Number: 12, Type: EventCleared, Time: went},
+		{Number: 12, Analog: true, Type: EventActivated, Time: restart},
+	}
+	if err := h.Record(events...); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := h.Query(Query{Number: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("%d records, want 2: %+v", len(records), records)
+	}
+	r := records[0]
+	if r.Analog || !r.Came.Equal(came) || !r.Went.Equal(went) || !r.Acked.Equal(restart) {
+		t.Errorf("record = %+v", r)
+	}
+	if d := r.Duration(time.Now()); d != 2*time.Hour {
+		t.Errorf("duration = %s", d)
+	}
+	if !records[1].Analog || !records[1].Went.IsZero() {
+		t.Errorf("analog record = %+v", records[1])
+	}
+}
+
+func TestQueryAnalog(t *testing.T) {
+
+	h, err := OpenHistory(filepath.Join(t.TempDir(), "alarms.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	came := time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)
+	if err := h.Record(
+		Event{Number: 12, Type: EventActivated, Time: came},
+		Event{Number: 12, Analog: true, Type: EventActivated, Time: came.Add(time.Minute)},
+		Event{Number: 13, Analog: true, Type: EventActivated, Time: came.Add(2 * time.Minute)},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	discrete, analog := false, true
+	tests := []struct {
+		number string
+		q      Query
+		want   []string
+	}{
+		{"12", Query{Number: 12, Analog: &discrete}, []string{"12"}},
+		{"A12", Query{Number: 12, Analog: &analog}, []string{"A12"}},
+		{"12 (both)", Query{Number: 12}, []string{"12", "A12"}},
+		{"analog", Query{Analog: &analog}, []string{"A12", "A13"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			records, err := h.Query(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range records {
+				got = append(got, FormatNumber(r.Number, r.Analog))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("records %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryClearedWhileStopped(t *testing.T) {
+
+	came := time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)
+	restart := came.Add(time.Hour)
+	again := came.Add(72 * time.Hour)
+
+	tests := []struct {
+		name   string
+		events []Event
+		went   time.Time
+	}{
+		{
+			// po ponownym uruchomieniu alarm jest nieaktywny, po kilku dniach przychodzi znowu
+			name: "inactive at restart",
+			events: []Event{
+				{Number: 12, Type: EventActivated, Time: came},
+				{Number: 12, Type: EventCleared, Time: restart, First: true},
+				{Number: 12, Type: EventActivated, Time: again},
+			},
+			went: restart,
+		},
+		{
+			// bez odczytu nieaktywnego alarmu wystąpienie kończy się z nowym przyjściem
+			name: "reactivated",
+			events: []Event{
+				{Number: 12, Type: EventActivated, Time: came},
+				{Number: 12, Type: EventActivated, Time: again},
+			},
+			went: again,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := OpenHistory(filepath.Join(t.TempDir(), "alarms.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close()
+
+			if err := h.Record(tt.events...); err != nil {
+				t.Fatal(err)
+			}
+			records, err := h.Query(Query{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 2 {
+				t.Fatalf("%d records, want 2: %+v", len(records), records)
+			}
+			if !records[0].Went.Equal(tt.went) {
+				t.Errorf("stale record went %s, want %s", records[0].Went, tt.went)
+			}
+			if r := records[1]; !r.Came.Equal(again) || !r.Went.IsZero() {
+				t.Errorf("new record = %+v", r)
+			}
+		})
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	for _, tt := range []struct {
+		s      string
+		number int
+		analog bool
+	}{{"12", 12, false}, {"A12", 12, true}, {" a7 ", 7, true}} {
+		number, analog, err := ParseNumber(tt.s)
+		if err != nil || number != tt.number || analog != tt.analog {
+			t.Errorf("ParseNumber(%q) = %d, %v, %v", tt.s, number, analog, err)
+		}
+		if n, a, _ := ParseNumber(FormatNumber(number, analog)); n != number || a != analog {
+			t.Errorf("FormatNumber(%d, %v) = %s", number, analog, FormatNumber(number, analog))
+		}
+	}
+	if _, _, err := ParseNumber("x"); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,479 @@
+// Package decoder dekoduje bloki bajtów odczytane przez IoT Gateway (tagi tab*, Byte Array)
+// z powrotem na wartości symboli i stany alarmów na podstawie tags.json i alarms.json.
+package decoder
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// Błędy dekodowania
+// ================================================================================================
+var (
+	ErrUnknownSymbol = errors.New("unknown symbol")
+	ErrNoBlock       = errors.New("no data for block tag")
+	ErrNoValue       = errors.New("no value for typed tag")
+	ErrShortBlock    = errors.New("block data too short")
+	ErrTypedTag      = errors.New("symbol has its own typed tag")
+	ErrAnalogAlarm   = errors.New("analog alarm has no trigger bit")
+	ErrNotAnalog     = errors.New("not an analog alarm")
+	ErrNotNumeric    = errors.New("value is not a number")
+)
+
+// Typy danych S7 rozpoznawane przez dekoder
+// ================================================================================================
+const (
+	kindBool = iota
+	kindByte
+	kindChar
+	kindSInt
+	kindWord
+	kindInt
+	kindDWord
+	kindDInt
+	kindTime
+	kindReal
+	kindString
+	kindDateTime
+)
+
+// s7Kind - rodzaj i rozmiar elementu typu danych S7
+// ================================================================================================
+type s7Kind struct {
+	kind int
+	size int
+}
+
+var s7Kinds = map[string]s7Kind{
+	"BOOL":          {kindBool, 0},
+	"BYTE":          {kindByte, 1},
+	"USINT":         {kindByte, 1},
+	"CHAR":          {kindChar, 1},
+	"SINT":          {kindSInt, 1},
+	"WORD":          {kindWord, 2},
+	"UINT":          {kindWord, 2},
+	"INT":           {kindInt, 2},
+	"DWORD":         {kindDWord, 4},
+	"UDINT":         {kindDWord, 4},
+	"DINT":          {kindDInt, 4},
+	"TIME":          {kindTime, 4},
+	"REAL":          {kindReal, 4},
+	"STRING":        {kindString, 0},
+	"DATE AND TIME": {kindDateTime, 8},
+	"DATE_AND_TIME": {kindDateTime, 8},
+}
+
+// s7SizeKinds - typ na podstawie rozmiaru, gdy tags.json nie zawiera typu symbolu
+// ================================================================================================
+var s7SizeKinds = map[int]string{1: "BYTE", 2: "WORD", 4: "DWORD"}
+
+// Decoder - dekoder bloków jednego projektu
+// ================================================================================================
+type Decoder struct {
+	Tags   tagsgen.Tags
+	Alarms tagsgen.Alarms
+
+	symbols map[string]tagsgen.CsvTag
+	byTag   map[string][]tagsgen.CsvTag
+}
+
+// New - dekoder dla opisu tagów i alarmów
+// ================================================================================================
+func New(tags tagsgen.Tags, alarms tagsgen.Alarms) *Decoder {
+
+	d := &Decoder{Tags: tags, Alarms: alarms, symbols: make(map[string]tagsgen.CsvTag), byTag: make(map[string][]tagsgen.CsvTag)}
+	for _, t := range tags.Tags {
+		if _, ok := d.symbols[t.SymbolName]; !ok {
+			d.symbols[t.SymbolName] = t
+			d.byTag[t.TagName] = append(d.byTag[t.TagName], t)
+		}
+	}
+	return d
+}
+
+// Load - dekoder z plików tags.json i alarms.json (alarmsFilename może być pusty)
+// ================================================================================================
+func Load(tagsFilename string, alarmsFilename string) (*Decoder, error) {
+
+	var tags tagsgen.Tags
+	var alarms tagsgen.Alarms
+
+	if err := readJSON(tagsFilename, &tags); err != nil {
+		return nil, err
+	}
+	if alarmsFilename != "" {
+		if err := readJSON(alarmsFilename, &alarms); err != nil {
+			return nil, err
+		}
+	}
+	return New(tags, alarms), nil
+}
+
+// readJSON - odczyt pliku json
+// ================================================================================================
+func readJSON(filename string, v interface{}) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
+
+// Symbol - opis symbolu z tags.json
+// ================================================================================================
+func (d *Decoder) Symbol(name string) (tagsgen.CsvTag, bool) {
+	t, ok := d.symbols[name]
+	return t, ok
+}
+
+// Decode - wartości wszystkich symboli, dla których są dane bloku (nazwa taga -> bajty)
+// Symbole z własnym tagiem z typem danych (tryb symbols / hybrid) są pomijane - IoT Gateway
+// przesyła ich wartości już zdekodowane.
+// ================================================================================================
+func (d *Decoder) Decode(blocks map[string][]byte) map[string]interface{} {
+
+	values := make(map[string]interface{})
+	for name, t := range d.symbols {
+		if _, ok := blocks[t.TagName]; !ok {
+			continue
+		}
+		if v, err := decodeTag(t, blocks[t.TagName]); err == nil {
+			values[name] = v
+		}
+	}
+	return values
+}
+
+// DecodeBlock - wartości symboli jednego bloku
+// ================================================================================================
+func (d *Decoder) DecodeBlock(tagName string, data []byte) map[string]interface{} {
+
+	values := make(map[string]interface{})
+	for _, t := range d.byTag[tagName] {
+		if v, err := decodeTag(t, data); err == nil {
+			values[t.SymbolName] = v
+		}
+	}
+	return values
+}
+
+// DecodeSymbol - wartość jednego symbolu
+// ================================================================================================
+func (d *Decoder) DecodeSymbol(name string, blocks map[string][]byte) (interface{}, error) {
+
+	t, ok := d.symbols[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSymbol, name)
+	}
+	data, ok := blocks[t.TagName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoBlock, t.TagName)
+	}
+	return decodeTag(t, data)
+}
+
+// AlarmActive - stan bitu wyzwalającego alarmu
+// Bajt wyzwalający w alarms.json uwzględnia już kolejność bajtów słowa S7.
+// ================================================================================================
+func AlarmActive(a tagsgen.CsvAlarm, blocks map[string][]byte) (bool, error) {
+
+	if a.BitNr < 0 {
+		return false, ErrAnalogAlarm
+	}
+	data, ok := blocks[a.TagName]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrNoBlock, a.TagName)
+	}
+	if a.Index >= len(data) || a.BitNr > 7 {
+		return false, fmt.Errorf("%w: %s[%d].%d", ErrShortBlock, a.TagName, a.Index, a.BitNr)
+	}
+	return data[a.Index]&(1<<uint(a.BitNr)) != 0, nil
+}
+
+// Value - wartość liczbowa wskazywanego taga: z bajtów bloku lub, dla taga z typem danych (tryb
+// symbols / hybrid), z wartości przesłanej przez IoT Gateway (nazwa taga -> wartość)
+// ================================================================================================
+func Value(v tagsgen.ValuePointer, blocks map[string][]byte, values map[string]interface{}) (float64, error) {
+
+	if v.DataType != "" && v.DataType != "Byte Array" {
+		value, ok := values[v.TagName]
+		if !ok {
+			return 0, fmt.Errorf("%w: %s", ErrNoValue, v.TagName)
+		}
+		return Number(value)
+	}
+
+	data, ok := blocks[v.TagName]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrNoBlock, v.TagName)
+	}
+	value, err := decodeTag(tagsgen.CsvTag{
+		SymbolName:     v.Tag,
+		SymbolDataType: v.SymbolDataType,
+		TagName:        v.TagName,
+		DataType:       v.DataType,
+		Index:          v.Index,
+		Size:           v.Size,
+	}, data)
+	if err != nil {
+		return 0, err
+	}
+	return Number(value)
+}
+
+// Number - wartość liczbowa (typy zwracane przez dekoder i liczby z json)
+// ================================================================================================
+func Number(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case uint8:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case time.Duration:
+		return float64(v / time.Millisecond), nil
+	}
+	return 0, fmt.Errorf("%w: %v", ErrNotNumeric, value)
+}
+
+// AnalogActive - stan alarmu analogowego dla wartości i granicy, jak w HMI: histereza przesuwa
+// próg przyjścia (coming), odejścia (going) lub oba; active - poprzedni stan alarmu
+// ================================================================================================
+func AnalogActive(a tagsgen.AnalogAlarm, value float64, limit float64, active bool) bool {
+
+	h := a.Hysteresis
+	if a.HysteresisPercent {
+		h = math.Abs(limit) * a.Hysteresis / 100
+	}
+	coming := a.HysteresisMode == tagsgen.HysteresisComing || a.HysteresisMode == tagsgen.HysteresisBoth
+	going := a.HysteresisMode == tagsgen.HysteresisGoing || a.HysteresisMode == tagsgen.HysteresisBoth
+
+	// próg przesunięty o histerezę: w górę dla przyjścia, w dół dla odejścia (odwrotnie dla below)
+	threshold := limit
+	switch {
+	case !active && coming:
+		threshold += h
+	case active && going:
+		threshold -= h
+	}
+
+	switch a.Mode {
+	case tagsgen.AnalogBelow, tagsgen.AnalogBelowOrEqual:
+		threshold = 2*limit - threshold
+		if a.Mode == tagsgen.AnalogBelow {
+			return value < threshold
+		}
+		return value <= threshold
+	case tagsgen.AnalogAboveOrEqual:
+		return value >= threshold
+	case tagsgen.AnalogEqual:
+		return value == limit
+	case tagsgen.AnalogUnequal:
+		return value != limit
+	}
+	return value > threshold
+}
+
+// AnalogAlarmActive - stan alarmu analogowego z wartości taga wyzwalającego i granicy w blokach
+// lub wartościach tagów z typem danych
+// ================================================================================================
+func AnalogAlarmActive(a tagsgen.CsvAlarm, blocks map[string][]byte, values map[string]interface{}, active bool) (bool, error) {
+
+	if a.Analog == nil {
+		return false, fmt.Errorf("%w: %d", ErrNotAnalog, a.Number)
+	}
+	value, err := Value(a.Analog.Trigger, blocks, values)
+	if err != nil {
+		return false, err
+	}
+	limit := a.Analog.Limit
+	if a.Analog.LimitTag != nil {
+		if limit, err = Value(*a.Analog.LimitTag, blocks, values); err != nil {
+			return false, err
+		}
+	}
+	return AnalogActive(*a.Analog, value, limit, active), nil
+}
+
+// ActiveAlarms - alarmy z ustawionym bitem wyzwalającym
+// ================================================================================================
+func (d *Decoder) ActiveAlarms(blocks map[string][]byte) (active []tagsgen.CsvAlarm) {
+	for _, a := range d.Alarms.Alarms {
+		if on, err := AlarmActive(a, blocks); err == nil && on {
+			active = append(active, a)
+		}
+	}
+	return
+}
+
+// symbolKind - typ danych symbolu i liczba elementów (tablice)
+// ================================================================================================
+func symbolKind(t tagsgen.CsvTag) (s7Kind, int) {
+
+	k, found := s7Kinds[strings.ToUpper(t.SymbolDataType)]
+	if !found {
+		if t.SymbolAddressLo != "" {
+			return s7Kinds["BOOL"], 1
+		}
+		k = s7Kinds[s7SizeKinds[t.Size]]
+		if k.size == 0 {
+			k = s7Kinds["BYTE"]
+		}
+	}
+
+	count := 1
+	if k.size > 0 && t.Size > k.size {
+		count = t.Size / k.size
+	}
+	return k, count
+}
+
+// decodeTag - wartość symbolu z bajtów bloku
+// ================================================================================================
+func decodeTag(t tagsgen.CsvTag, data []byte) (interface{}, error) {
+
+	if t.DataType != "" && t.DataType != "Byte Array" {
+		return nil, fmt.Errorf("%w: %s", ErrTypedTag, t.TagName)
+	}
+
+	k, count := symbolKind(t)
+
+	if k.kind == kindBool {
+		if t.Index >= len(data) || t.BitNr < 0 || t.BitNr > 7 {
+			return nil, fmt.Errorf("%w: %s[%d].%d", ErrShortBlock, t.TagName, t.Index, t.BitNr)
+		}
+		return data[t.Index]&(1<<uint(t.BitNr)) != 0, nil
+	}
+
+	size := k.size * count
+	if k.kind == kindString {
+		size = t.Size
+	}
+	if t.Index < 0 || t.Index+size > len(data) {
+		return nil, fmt.Errorf("%w: %s[%d], %d bytes", ErrShortBlock, t.TagName, t.Index, size)
+	}
+	b := data[t.Index : t.Index+size]
+
+	if k.kind == kindString {
+		return decodeString(b), nil
+	}
+	if count == 1 {
+		return decodeElem(k.kind, b), nil
+	}
+
+	values := make([]interface{}, count)
+	for i := range values {
+		values[i] = decodeElem(k.kind, b[i*k.size:])
+	}
+	return values, nil
+}
+
+// decodeElem - element S7 (big-endian)
+// ================================================================================================
+func decodeElem(kind int, b []byte) interface{} {
+	switch kind {
+	case kindByte:
+		return b[0]
+	case kindChar:
+		return string(rune(b[0]))
+	case kindSInt:
+		return int8(b[0])
+	case kindWord:
+		return binary.BigEndian.Uint16(b)
+	case kindInt:
+		return int16(binary.BigEndian.Uint16(b))
+	case kindDWord:
+		return binary.BigEndian.Uint32(b)
+	case kindDInt:
+		return int32(binary.BigEndian.Uint32(b))
+	case kindTime:
+		return time.Duration(int32(binary.BigEndian.Uint32(b))) * time.Millisecond
+	case kindReal:
+		return math.Float32frombits(binary.BigEndian.Uint32(b))
+	case kindDateTime:
+		return decodeDateTime(b)
+	}
+	return nil
+}
+
+// decodeString - STRING S7: długość maksymalna, długość aktualna, znaki
+// ================================================================================================
+func decodeString(b []byte) string {
+	if len(b) < 2 {
+		return ""
+	}
+	n := int(b[1])
+	if n > len(b)-2 {
+		n = len(b) - 2
+	}
+	return string(b[2 : 2+n])
+}
+
+// bcd - bajt w kodzie BCD
+// ================================================================================================
+func bcd(b byte) int {
+	return int(b>>4)*10 + int(b&0x0f)
+}
+
+// decodeDateTime - DATE_AND_TIME S7 (BCD): rok, miesiąc, dzień, godzina, minuta, sekunda, ms
+// ================================================================================================
+func decodeDateTime(b []byte) time.Time {
+	year := bcd(b[0])
+	if year < 90 {
+		year += 2000
+	} else {
+		year += 1900
+	}
+	ms := bcd(b[6])*10 + int(b[7]>>4)
+	return time.Date(year, time.Month(bcd(b[1])), bcd(b[2]), bcd(b[3]), bcd(b[4]), bcd(b[5]), ms*int(time.Millisecond), time.Local)
+}
+
+// FormatValue - wartość symbolu jako tekst
+// ================================================================================================
+func FormatValue(v interface{}) string {
+	switch v := v.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.000")
+	case []interface{}:
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = FormatValue(v[i])
+		}
+		return "[" + strings.Join(s, " ") + "]"
+	}
+	return fmt.Sprint(v)
+}
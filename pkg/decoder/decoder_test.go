@@ -0,0 +1,235 @@
+package decoder
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+func TestDecodeTag(t *testing.T) {
+
+	data := []byte{
+		0xff, 0x38, // 0: INT -200
+		0x12, 0x34, // 2: WORD 0x1234
+		0xff, 0xff, 0xfe, 0x0c, // 4: DINT -500
+		0x42, 0xf6, 0xe9, 0x79, // 8: REAL 123.456
+		0x06, 0x03, 'a', 'b', 'c', 0, 0, 0, // 12: STRING[4], "abc"
+		0x00, 0x01, 0x00, 0x02, // 20: Array[0..1] of INT
+	}
+
+	tests := []struct {
+		name string
+		tag  tagsgen.CsvTag
+		want interface{}
+	}{
+		{"INT", tagsgen.CsvTag{SymbolDataType: "Int", Index: 0, Size: 2}, int16(-200)},
+		{"WORD", tagsgen.CsvTag{SymbolDataType: "WORD", Index: 2, Size: 2}, uint16(0x1234)},
+		{"DINT", tagsgen.CsvTag{SymbolDataType: "DInt", Index: 4, Size: 4}, int32(-500)},
+		{"REAL", tagsgen.CsvTag{SymbolDataType: "Real", Index: 8, Size: 4}, float32(123.456)},
+		{"STRING", tagsgen.CsvTag{SymbolDataType: "String", Index: 12, Size: 8}, "abc"},
+		{"size without type", tagsgen.CsvTag{Index: 2, Size: 2}, uint16(0x1234)},
+		{"Byte Array tag", tagsgen.CsvTag{SymbolDataType: "INT", DataType: "Byte Array", Index: 0, Size: 2}, int16(-200)},
+		{"BOOL", tagsgen.CsvTag{SymbolDataType: "Bool", Index: 3, BitNr: 2}, true},
+		{"BOOL off", tagsgen.CsvTag{SymbolDataType: "Bool", Index: 3, BitNr: 0}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeTag(tt.tag, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+
+	t.Run("INT array", func(t *testing.T) {
+		got, err := decodeTag(tagsgen.CsvTag{SymbolDataType: "INT", Index: 20, Size: 4}, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		arr, ok := got.([]interface{})
+		if !ok || len(arr) != 2 || arr[0] != int16(1) || arr[1] != int16(2) {
+			t.Errorf("got %v", got)
+		}
+	})
+}
+
+func TestDecodeTagErrors(t *testing.T) {
+
+	data := make([]byte, 4)
+
+	tests := []struct {
+		name string
+		tag  tagsgen.CsvTag
+		err  error
+	}{
+		{"index past block", tagsgen.CsvTag{SymbolDataType: "INT", Index: 4, Size: 2}, ErrShortBlock},
+		{"value past block", tagsgen.CsvTag{SymbolDataType: "DINT", Index: 2, Size: 4}, ErrShortBlock},
+		{"negative index", tagsgen.CsvTag{SymbolDataType: "INT", Index: -1, Size: 2}, ErrShortBlock},
+		{"bit past block", tagsgen.CsvTag{SymbolDataType: "BOOL", Index: 4}, ErrShortBlock},
+		{"bit number", tagsgen.CsvTag{SymbolDataType: "BOOL", Index: 0, BitNr: 8}, ErrShortBlock},
+		{"typed tag", tagsgen.CsvTag{SymbolDataType: "INT", DataType: "Short", Size: 2}, ErrTypedTag},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeTag(tt.tag, data); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAlarmActive(t *testing.T) {
+
+	// bit 9 słowa S7 MW0 leży w bajcie 0 (starszy bajt), alarms.json zawiera już bajt po zamianie ^1
+	blocks := map[string][]byte{"tabMB_0": {0x02, 0x00}}
+
+	tests := []struct {
+		name string
+		a    tagsgen.CsvAlarm
+		want bool
+		err  error
+	}{
+		{"bit 9", tagsgen.CsvAlarm{TagName: "tabMB_0", Index: (9 / 8) ^ 1, BitNr: 9 % 8}, true, nil},
+		{"bit 1", tagsgen.CsvAlarm{TagName: "tabMB_0", Index: (1 / 8) ^ 1, BitNr: 1 % 8}, false, nil},
+		{"outside block", tagsgen.CsvAlarm{TagName: "tabMB_0", Index: 2, BitNr: 0}, false, ErrShortBlock},
+		{"no block", tagsgen.CsvAlarm{TagName: "tabMB_8", Index: 0, BitNr: 0}, false, ErrNoBlock},
+		{"analog", tagsgen.CsvAlarm{TagName: "tabMB_0", BitNr: -1}, false, ErrAnalogAlarm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			on, err := AlarmActive(tt.a, blocks)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if on != tt.want {
+				t.Errorf("active = %v, want %v", on, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSkipsTypedTags(t *testing.T) {
+
+	d := New(tagsgen.Tags{Tags: []tagsgen.CsvTag{
+		{SymbolName: "Speed", SymbolDataType: "INT", TagName: "tabMB_0", DataType: "Byte Array", Index: 0, Size: 2},
+		{SymbolName: "Temperature", SymbolDataType: "REAL", TagName: "Temperature", DataType: "Float", Size: 4},
+	}}, tagsgen.Alarms{})
+
+	blocks := map[string][]byte{"tabMB_0": {0x00, 0x2a}, "Temperature": {0, 0, 0, 0}}
+	values := d.Decode(blocks)
+	if values["Speed"] != int16(42) {
+		t.Errorf("Speed = %v", values["Speed"])
+	}
+	if _, ok := values["Temperature"]; ok {
+		t.Error("typed tag decoded from block data")
+	}
+	if _, err := d.DecodeSymbol("Temperature", blocks); !errors.Is(err, ErrTypedTag) {
+		t.Errorf("DecodeSymbol typed tag: err = %v", err)
+	}
+	if _, err := d.DecodeSymbol("Pressure", blocks); !errors.Is(err, ErrUnknownSymbol) {
+		t.Errorf("DecodeSymbol unknown: err = %v", err)
+	}
+}
+
+// analogAlarm - alarm analogowy Temperature > TempLimit wygenerowany w danym trybie
+// ================================================================================================
+func analogAlarm(t *testing.T, mode string) (tagsgen.CsvAlarm, []tagsgen.KepTag) {
+	t.Helper()
+
+	p := tagsgen.NewProject()
+	p.Symbols = []tagsgen.Symbol{
+		{Name: "Temperature", Periph: "MD", AddressHi: "20", Type: "REAL", Size: "4", DataType: "REAL"},
+		{Name: "TempLimit", Periph: "MW", AddressHi: "24", Type: "INT", Size: "2", DataType: "INT"},
+	}
+	p.AlarmDefs = []tagsgen.AlarmDef{{Number: 1, TriggerTag: "Temperature", TriggerBitNr: -1, Analog: true, Limit: "TempLimit", LimitMode: tagsgen.AnalogAbove}}
+	p.Alarms.SourceFilename = "Alarms.csv"
+
+	opts := tagsgen.DefaultOptions()
+	opts.Mode = mode
+	res, err := p.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Alarms.Alarms) != 1 {
+		t.Fatalf("alarms = %+v, unresolved = %+v", res.Alarms.Alarms, res.UnresolvedAlarms)
+	}
+	return res.Alarms.Alarms[0], p.KepTags
+}
+
+// setValue - wartość wskazywanego taga w blokach (big-endian) lub w wartościach tagów z typem danych
+// ================================================================================================
+func setValue(v tagsgen.ValuePointer, value float64, blocks map[string][]byte, values map[string]interface{}) {
+	data, ok := blocks[v.TagName]
+	if !ok {
+		values[v.TagName] = value
+		return
+	}
+	switch v.SymbolDataType {
+	case "REAL":
+		binary.BigEndian.PutUint32(data[v.Index:], math.Float32bits(float32(value)))
+	case "INT":
+		binary.BigEndian.PutUint16(data[v.Index:], uint16(int16(value)))
+	}
+}
+
+func TestAnalogAlarmActiveModes(t *testing.T) {
+
+	for _, mode := range []string{tagsgen.ModeBlocks, tagsgen.ModeSymbols, tagsgen.ModeHybrid} {
+		t.Run(mode, func(t *testing.T) {
+
+			a, kepTags := analogAlarm(t, mode)
+
+			typed := mode != tagsgen.ModeBlocks
+			if got := a.Analog.Trigger.DataType != "Byte Array"; got != typed {
+				t.Fatalf("trigger data type %q in mode %s", a.Analog.Trigger.DataType, mode)
+			}
+
+			blocks := make(map[string][]byte)
+			for _, k := range kepTags {
+				if k.DataType == "Byte Array" {
+					blocks[k.Name] = make([]byte, k.Size)
+				}
+			}
+			values := make(map[string]interface{})
+
+			for _, c := range []struct {
+				value, limit float64
+				want         bool
+			}{
+				{80, 100, false},
+				{100.5, 100, true},
+				{-5, -10, true},
+			} {
+				setValue(a.Analog.Trigger, c.value, blocks, values)
+				setValue(*a.Analog.LimitTag, c.limit, blocks, values)
+
+				on, err := AnalogAlarmActive(a, blocks, values, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if on != c.want {
+					t.Errorf("%g > %g = %v, want %v", c.value, c.limit, on, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValueTypedTagWithoutValue(t *testing.T) {
+	v := tagsgen.ValuePointer{Tag: "Temperature", TagName: "Temperature", DataType: "Float"}
+	if _, err := Value(v, nil, map[string]interface{}{}); err == nil {
+		t.Error("expected error for typed tag without value")
+	}
+	if n, err := Value(v, nil, map[string]interface{}{"Temperature": 21.5}); err != nil || n != 21.5 {
+		t.Errorf("Value = %g, %v", n, err)
+	}
+}
@@ -0,0 +1,126 @@
+package decoder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidByteArray - wartość taga Byte Array nie jest tablicą bajtów
+// ================================================================================================
+var ErrInvalidByteArray = errors.New("invalid byte array value")
+
+// IotValue - wartość jednego taga w komunikacie IoT Gateway
+// {"id":"SiemensTCPIP.PLC.tabIB_0","v":[0,12,255],"q":true,"t":1497986181938}
+// ================================================================================================
+type IotValue struct {
+	ID        string          `json:"id"`
+	V         json.RawMessage `json:"v"`
+	Quality   bool            `json:"q"`
+	Timestamp int64           `json:"t"`
+}
+
+// IotPayload - komunikat IoT Gateway (szablon standardowy MQTT / REST)
+// {"timestamp":1497986184213,"values":[{"id":...,"v":...,"q":true,"t":...}]}
+// ================================================================================================
+type IotPayload struct {
+	Timestamp int64      `json:"timestamp"`
+	Values    []IotValue `json:"values"`
+}
+
+// ParsePayload - odczyt komunikatu IoT Gateway
+// ================================================================================================
+func ParsePayload(data []byte) (*IotPayload, error) {
+	var p IotPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Bytes - wartość taga Byte Array jako bajty
+// ================================================================================================
+func (v IotValue) Bytes() ([]byte, error) {
+
+	var numbers []int
+	if err := json.Unmarshal(v.V, &numbers); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidByteArray, v.ID)
+	}
+
+	data := make([]byte, len(numbers))
+	for i, n := range numbers {
+		// Kepware Byte jest bez znaku, Char ze znakiem
+		if n < -128 || n > 255 {
+			return nil, fmt.Errorf("%w: %s[%d] = %d", ErrInvalidByteArray, v.ID, i, n)
+		}
+		data[i] = byte(n)
+	}
+	return data, nil
+}
+
+// TagName - nazwa taga Kepware bez nazwy połączenia (kanał.urządzenie) z tags.json
+// ================================================================================================
+func (d *Decoder) TagName(id string) string {
+	if d.Tags.ConnectionName != "" && strings.HasPrefix(id, d.Tags.ConnectionName+".") {
+		return strings.TrimPrefix(id, d.Tags.ConnectionName+".")
+	}
+	return id
+}
+
+// IsBlock - czy tag jest blokiem (Byte Array) z symbolami
+// ================================================================================================
+func (d *Decoder) IsBlock(tagName string) bool {
+	tags := d.byTag[tagName]
+	return len(tags) > 0 && (tags[0].DataType == "" || tags[0].DataType == "Byte Array")
+}
+
+// DecodeValues - wartości symboli z wartości tagów IoT Gateway
+// Wartości ze złą jakością (q = false) i tagi spoza tags.json są pomijane. Symbole z własnym
+// tagiem z typem danych dostają wartość taga w postaci z komunikatu (liczba, bool, string).
+// Zwracane są także bloki (nazwa taga -> bajty) do oceny alarmów.
+// ================================================================================================
+func (d *Decoder) DecodeValues(values []IotValue) (symbols map[string]interface{}, blocks map[string][]byte, err error) {
+
+	symbols = make(map[string]interface{})
+	blocks = make(map[string][]byte)
+
+	var errs []string
+	for _, v := range values {
+		if !v.Quality {
+			continue
+		}
+
+		tagName := d.TagName(v.ID)
+		if len(d.byTag[tagName]) == 0 {
+			continue
+		}
+
+		if d.IsBlock(tagName) {
+			data, e := v.Bytes()
+			if e != nil {
+				errs = append(errs, e.Error())
+				continue
+			}
+			blocks[tagName] = data
+			for name, value := range d.DecodeBlock(tagName, data) {
+				symbols[name] = value
+			}
+			continue
+		}
+
+		var value interface{}
+		if e := json.Unmarshal(v.V, &value); e != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", v.ID, e))
+			continue
+		}
+		for _, t := range d.byTag[tagName] {
+			symbols[t.SymbolName] = value
+		}
+	}
+
+	if len(errs) > 0 {
+		err = errors.New(strings.Join(errs, "; "))
+	}
+	return
+}
@@ -0,0 +1,92 @@
+package decoder
+
+import (
+	"testing"
+
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// recordedPayload - komunikat agenta MQTT IoT Gateway (szablon standardowy)
+// ================================================================================================
+const recordedPayload = `{"timestamp":1497986184213,"values":[
+{"id":"SiemensTCPIP.PLC.tabMB_0","v":[1,0,0,42],"q":true,"t":1497986181938},
+{"id":"SiemensTCPIP.PLC.Temperature","v":21.5,"q":true,"t":1497986181938},
+{"id":"SiemensTCPIP.PLC.tabMB_8","v":[255],"q":false,"t":1497986181938},
+{"id":"SiemensTCPIP.PLC.Unknown","v":1,"q":true,"t":1497986181938},
+{"id":"_System._Time","v":"12:00:00","q":true,"t":1497986181938}
+]}`
+
+// testDecoder - dekoder z blokiem tabMB_0, tagiem z typem danych Temperature i blokiem tabMB_8
+// ================================================================================================
+func testDecoder() *Decoder {
+	return New(tagsgen.Tags{ConnectionName: "SiemensTCPIP.PLC", Tags: []tagsgen.CsvTag{
+		{SymbolName: "Running", SymbolAddressLo: "0", TagName: "tabMB_0", DataType: "Byte Array", Index: 0, BitNr: 0},
+		{SymbolName: "Speed", SymbolDataType: "INT", TagName: "tabMB_0", DataType: "Byte Array", Index: 2, Size: 2},
+		{SymbolName: "Temperature", SymbolDataType: "REAL", TagName: "Temperature", DataType: "Float", Size: 4},
+		{SymbolName: "Mode", SymbolDataType: "BYTE", TagName: "tabMB_8", DataType: "Byte Array", Index: 0, Size: 1},
+	}}, tagsgen.Alarms{})
+}
+
+func TestParsePayload(t *testing.T) {
+
+	p, err := ParsePayload([]byte(recordedPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Timestamp != 1497986184213 || len(p.Values) != 5 {
+		t.Fatalf("payload = %+v", p)
+	}
+	if v := p.Values[0]; v.ID != "SiemensTCPIP.PLC.tabMB_0" || !v.Quality || v.Timestamp != 1497986181938 {
+		t.Errorf("value = %+v", v)
+	}
+	if _, err := ParsePayload([]byte(`{"values":`)); err == nil {
+		t.Error("expected error for truncated payload")
+	}
+}
+
+func TestDecodeValues(t *testing.T) {
+
+	p, err := ParsePayload([]byte(recordedPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := testDecoder()
+	symbols, blocks, err := d.DecodeValues(p.Values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{"Running": true, "Speed": int16(42), "Temperature": 21.5}
+	if len(symbols) != len(want) {
+		t.Errorf("symbols = %v", symbols)
+	}
+	for name, v := range want {
+		if symbols[name] != v {
+			t.Errorf("%s = %v (%T), want %v (%T)", name, symbols[name], symbols[name], v, v)
+		}
+	}
+
+	// blok ze złą jakością i tagi spoza tags.json są pomijane
+	if len(blocks) != 1 || len(blocks["tabMB_0"]) != 4 {
+		t.Errorf("blocks = %v", blocks)
+	}
+}
+
+func TestDecodeValuesInvalidByteArray(t *testing.T) {
+
+	p, err := ParsePayload([]byte(`{"values":[
+{"id":"SiemensTCPIP.PLC.tabMB_0","v":[1,0,0,300],"q":true,"t":0},
+{"id":"SiemensTCPIP.PLC.tabMB_8","v":[7],"q":true,"t":0}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	symbols, _, err := testDecoder().DecodeValues(p.Values)
+	if err == nil {
+		t.Error("expected error for byte out of range")
+	}
+	if symbols["Mode"] != uint8(7) {
+		t.Errorf("valid block not decoded: %v", symbols)
+	}
+}
@@ -0,0 +1,321 @@
+// Package ingest przyjmuje komunikaty agenta REST Client IoT Gateway (HTTP POST), sprawdza je
+// z listą tagów z tags.json (pozycje iot.csv), dekoduje bloki i przekazuje wartości symboli do
+// odbiornika (Sink).
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dkt64/tagsgenerator/pkg/decoder"
+)
+
+// MaxPayloadSize - największy przyjmowany komunikat
+// ================================================================================================
+const MaxPayloadSize = 16 << 20
+
+// Błędy sprawdzania komunikatu
+// ================================================================================================
+var (
+	ErrInvalidPayload = errors.New("invalid IoT Gateway payload")
+	ErrUnknownItem    = errors.New("unknown IoT Gateway item")
+	ErrInvalidItem    = errors.New("invalid IoT Gateway item")
+)
+
+// ItemError - odrzucona pozycja komunikatu
+// ================================================================================================
+type ItemError struct {
+	ID  string
+	Err error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("%s: %v", e.ID, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// item - pozycja komunikatu przed sprawdzeniem obecności pól
+// ================================================================================================
+type item struct {
+	ID        *string         `json:"id"`
+	V         json.RawMessage `json:"v"`
+	Quality   *bool           `json:"q"`
+	Timestamp *int64          `json:"t"`
+}
+
+// payload - komunikat szablonu standardowego {"timestamp":...,"values":[...]}
+// ================================================================================================
+type payload struct {
+	Timestamp int64             `json:"timestamp"`
+	Values    []json.RawMessage `json:"values"`
+}
+
+// Handler - odbiór komunikatów IoT Gateway dla jednego projektu
+// ================================================================================================
+type Handler struct {
+	Decoder *decoder.Decoder
+	Sink    Sink
+
+	items map[string]string   // id pozycji IoT Gateway -> typ danych Kepware
+	byTag map[string][]string // tag -> symbole
+}
+
+// NewHandler - odbiór komunikatów dla tagów dekodera; wartości trafiają do sink
+// ================================================================================================
+func NewHandler(dec *decoder.Decoder, sink Sink) *Handler {
+
+	h := &Handler{Decoder: dec, Sink: sink, items: make(map[string]string), byTag: make(map[string][]string)}
+	for _, t := range dec.Tags.Tags {
+		id := t.TagName
+		if dec.Tags.ConnectionName != "" {
+			id = dec.Tags.ConnectionName + "." + t.TagName
+		}
+		dataType := t.DataType
+		if dataType == "" {
+			dataType = "Byte Array"
+		}
+		h.items[id] = dataType
+		h.byTag[t.TagName] = append(h.byTag[t.TagName], t.SymbolName)
+	}
+	return h
+}
+
+// Parse - pozycje komunikatu: szablon standardowy {"timestamp":...,"values":[...]} albo sama
+// tablica pozycji [{"id":...,"v":...,"q":...,"t":...}]
+// Pozycje niezgodne z listą tagów są zwracane jako rejected, reszta jako items.
+// ================================================================================================
+func (h *Handler) Parse(data []byte) (items []decoder.IotValue, rejected []*ItemError, err error) {
+
+	var p payload
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &p.Values)
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&p)
+		if err == nil && p.Values == nil {
+			err = errors.New(`missing "values"`)
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	for i, raw := range p.Values {
+		v, e := h.checkItem(raw)
+		if e != nil {
+			if e.ID == "" {
+				e.ID = fmt.Sprintf("values[%d]", i)
+			}
+			rejected = append(rejected, e)
+			continue
+		}
+		items = append(items, v)
+	}
+	return
+}
+
+// checkItem - sprawdzenie jednej pozycji: komplet pól, znany tag, wartość zgodna z typem danych
+// ================================================================================================
+func (h *Handler) checkItem(raw json.RawMessage) (decoder.IotValue, *ItemError) {
+
+	var it item
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&it); err != nil {
+		return decoder.IotValue{}, &ItemError{Err: fmt.Errorf("%w: %v", ErrInvalidItem, err)}
+	}
+
+	if it.ID == nil || *it.ID == "" {
+		return decoder.IotValue{}, &ItemError{Err: fmt.Errorf(`%w: missing "id"`, ErrInvalidItem)}
+	}
+	id := *it.ID
+
+	var missing []string
+	if it.V == nil {
+		missing = append(missing, `"v"`)
+	}
+	if it.Quality == nil {
+		missing = append(missing, `"q"`)
+	}
+	if it.Timestamp == nil {
+		missing = append(missing, `"t"`)
+	}
+	if len(missing) > 0 {
+		return decoder.IotValue{}, &ItemError{ID: id, Err: fmt.Errorf("%w: missing %s", ErrInvalidItem, strings.Join(missing, ", "))}
+	}
+
+	dataType, ok := h.items[id]
+	if !ok {
+		return decoder.IotValue{}, &ItemError{ID: id, Err: ErrUnknownItem}
+	}
+
+	v := decoder.IotValue{ID: id, V: it.V, Quality: *it.Quality, Timestamp: *it.Timestamp}
+
+	// przy złej jakości IoT Gateway przesyła ostatnią (lub pustą) wartość - bez sprawdzania
+	if v.Quality {
+		if err := checkValue(v, dataType); err != nil {
+			return decoder.IotValue{}, &ItemError{ID: id, Err: err}
+		}
+	}
+	return v, nil
+}
+
+// checkValue - wartość zgodna z typem danych taga Kepware (Byte Array, Word, Float Array, ...)
+// ================================================================================================
+func checkValue(v decoder.IotValue, dataType string) error {
+
+	if dataType == "Byte Array" {
+		_, err := v.Bytes()
+		return err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(v.V, &value); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidItem, err)
+	}
+
+	elemType := strings.TrimSuffix(dataType, " Array")
+	elems := []interface{}{value}
+	if elemType != dataType {
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%w: %s value is not an array", ErrInvalidItem, dataType)
+		}
+		elems = arr
+	}
+
+	for _, e := range elems {
+		ok := false
+		switch e.(type) {
+		case bool:
+			ok = elemType == "Boolean"
+		case float64:
+			ok = elemType != "String" && elemType != "Date"
+		case string:
+			ok = elemType == "String" || elemType == "Date"
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s value %v", ErrInvalidItem, dataType, e)
+		}
+	}
+	return nil
+}
+
+// Ingest - dekodowanie sprawdzonych pozycji i przekazanie wartości do odbiornika
+// ================================================================================================
+func (h *Handler) Ingest(items []decoder.IotValue) (*Batch, error) {
+
+	b := &Batch{Received: time.Now(), Items: items}
+
+	for _, v := range items {
+		stamp := b.Received
+		if v.Timestamp > 0 {
+			stamp = time.Unix(0, v.Timestamp*int64(time.Millisecond))
+		}
+
+		tagName := h.Decoder.TagName(v.ID)
+		values, _, err := h.Decoder.DecodeValues([]decoder.IotValue{v})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range h.byTag[tagName] {
+			sv := SymbolValue{Symbol: name, TagName: tagName, Quality: v.Quality, Time: stamp}
+			if v.Quality {
+				value, ok := values[name]
+				if !ok {
+					// blok krótszy niż symbol
+					sv.Quality = false
+				}
+				sv.Value = value
+			}
+			b.Symbols = append(b.Symbols, sv)
+		}
+	}
+
+	if h.Sink != nil {
+		if err := h.Sink.Write(b); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// response - odpowiedź na POST: liczba przyjętych i lista odrzuconych pozycji
+// ================================================================================================
+type response struct {
+	Accepted int            `json:"accepted"`
+	Symbols  int            `json:"symbols"`
+	Rejected []rejectedItem `json:"rejected,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+type rejectedItem struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// ServeHTTP - POST komunikatu agenta REST Client
+// 200 - wszystkie pozycje przyjęte, 422 - część pozycji odrzucona (pozostałe przyjęte),
+// 400 - niepoprawny komunikat, 500 - błąd odbiornika
+// ================================================================================================
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeResponse(w, http.StatusMethodNotAllowed, response{Error: "method not allowed"})
+		return
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxPayloadSize))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, response{Error: err.Error()})
+		return
+	}
+
+	items, rejected, err := h.Parse(data)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, response{Error: err.Error()})
+		return
+	}
+
+	resp := response{Accepted: len(items)}
+	for _, e := range rejected {
+		resp.Rejected = append(resp.Rejected, rejectedItem{ID: e.ID, Error: e.Err.Error()})
+	}
+
+	b, err := h.Ingest(items)
+	if b != nil {
+		resp.Symbols = len(b.Symbols)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		writeResponse(w, http.StatusInternalServerError, resp)
+		return
+	}
+
+	status := http.StatusOK
+	if len(rejected) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	writeResponse(w, status, resp)
+}
+
+// writeResponse - odpowiedź json
+// ================================================================================================
+func writeResponse(w http.ResponseWriter, status int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
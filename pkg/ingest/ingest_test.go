@@ -0,0 +1,163 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dkt64/tagsgenerator/pkg/decoder"
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// testHandler - odbiór komunikatów dla bloku tabMB_0 i taga z typem danych Temperature
+// ================================================================================================
+func testHandler(sink Sink) *Handler {
+	dec := decoder.New(tagsgen.Tags{ConnectionName: "SiemensTCPIP.PLC", Tags: []tagsgen.CsvTag{
+		{SymbolName: "Speed", SymbolDataType: "INT", TagName: "tabMB_0", DataType: "Byte Array", Index: 0, Size: 2},
+		{SymbolName: "Temperature", SymbolDataType: "REAL", TagName: "Temperature", DataType: "Float", Size: 4},
+	}}, tagsgen.Alarms{})
+	return NewHandler(dec, sink)
+}
+
+// post - POST komunikatu; status i odpowiedź
+// ================================================================================================
+func post(t *testing.T, h http.Handler, body string) (int, response) {
+	t.Helper()
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, r
+}
+
+func TestServeHTTP(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		accepted int
+		rejected []string
+	}{
+		{
+			name:     "all accepted",
+			body:     `{"timestamp":1,"values":[{"id":"SiemensTCPIP.PLC.tabMB_0","v":[0,42],"q":true,"t":1},{"id":"SiemensTCPIP.PLC.Temperature","v":21.5,"q":true,"t":1}]}`,
+			status:   http.StatusOK,
+			accepted: 2,
+		},
+		{
+			name:     "plain array",
+			body:     `[{"id":"SiemensTCPIP.PLC.Temperature","v":21.5,"q":true,"t":1}]`,
+			status:   http.StatusOK,
+			accepted: 1,
+		},
+		{
+			name: "partially rejected",
+			body: `{"timestamp":1,"values":[
+{"id":"SiemensTCPIP.PLC.tabMB_0","v":[0,42],"q":true,"t":1},
+{"id":"SiemensTCPIP.PLC.Unknown","v":1,"q":true,"t":1},
+{"id":"SiemensTCPIP.PLC.Temperature","v":"warm","q":true,"t":1},
+{"id":"SiemensTCPIP.PLC.tabMB_0","v":[0,42],"q":true},
+{"id":"SiemensTCPIP.PLC.tabMB_0","v":[0,42],"q":true,"t":1,"x":0}]}`,
+			status:   http.StatusUnprocessableEntity,
+			accepted: 1,
+			rejected: []string{"SiemensTCPIP.PLC.Unknown", "SiemensTCPIP.PLC.Temperature", "SiemensTCPIP.PLC.tabMB_0", "values[4]"},
+		},
+		{
+			name:     "bad quality value not checked",
+			body:     `{"timestamp":1,"values":[{"id":"SiemensTCPIP.PLC.Temperature","v":"","q":false,"t":1}]}`,
+			status:   http.StatusOK,
+			accepted: 1,
+		},
+		{
+			name:   "unknown field",
+			body:   `{"timestamp":1,"values":[],"extra":true}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "missing values",
+			body:   `{"timestamp":1}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid json",
+			body:   `{"timestamp":`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, r := post(t, testHandler(nil), tt.body)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d: %+v", status, tt.status, r)
+			}
+			if r.Accepted != tt.accepted {
+				t.Errorf("accepted = %d, want %d", r.Accepted, tt.accepted)
+			}
+			if len(r.Rejected) != len(tt.rejected) {
+				t.Fatalf("rejected = %+v, want %v", r.Rejected, tt.rejected)
+			}
+			for i, id := range tt.rejected {
+				if r.Rejected[i].ID != id {
+					t.Errorf("rejected[%d] = %+v, want %s", i, r.Rejected[i], id)
+				}
+			}
+		})
+	}
+}
+
+func TestServeHTTPSink(t *testing.T) {
+
+	var got []SymbolValue
+	h := testHandler(SinkFunc(func(b *Batch) error {
+		got = append(got, b.Symbols...)
+		return nil
+	}))
+
+	status, _ := post(t, h, `{"timestamp":1,"values":[{"id":"SiemensTCPIP.PLC.tabMB_0","v":[0,42],"q":true,"t":1}]}`)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	if len(got) != 1 || got[0].Symbol != "Speed" || got[0].Value != int16(42) || !got[0].Quality {
+		t.Errorf("symbols = %+v", got)
+	}
+}
+
+func TestServeHTTPSinkError(t *testing.T) {
+
+	h := testHandler(Sinks{
+		SinkFunc(func(b *Batch) error { return nil }),
+		SinkFunc(func(b *Batch) error { return errors.New("disk full") }),
+	})
+
+	status, r := post(t, h, `{"timestamp":1,"values":[{"id":"SiemensTCPIP.PLC.Temperature","v":21.5,"q":true,"t":1}]}`)
+	if status != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", status)
+	}
+	if r.Error != "disk full" || r.Accepted != 1 || r.Symbols != 1 {
+		t.Errorf("response = %+v", r)
+	}
+}
+
+func TestServeHTTPMethod(t *testing.T) {
+
+	rec := httptest.NewRecorder()
+	testHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/iotgateway", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("GET: %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
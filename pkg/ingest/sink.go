@@ -0,0 +1,92 @@
+package ingest
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dkt64/tagsgenerator/pkg/decoder"
+)
+
+// SymbolValue - wartość symbolu z komunikatu IoT Gateway
+// Przy złej jakości (Quality = false) Value jest puste.
+// ================================================================================================
+type SymbolValue struct {
+	Symbol  string
+	TagName string
+	Value   interface{}
+	Quality bool
+	Time    time.Time
+}
+
+// Batch - jeden przyjęty komunikat: sprawdzone pozycje IoT Gateway i wartości symboli
+// ================================================================================================
+type Batch struct {
+	Received time.Time
+	Items    []decoder.IotValue
+	Symbols  []SymbolValue
+}
+
+// Sink - odbiornik wartości symboli
+// ================================================================================================
+type Sink interface {
+	Write(b *Batch) error
+}
+
+// SinkFunc - funkcja jako odbiornik
+// ================================================================================================
+type SinkFunc func(b *Batch) error
+
+// Write - wywołanie funkcji
+func (f SinkFunc) Write(b *Batch) error {
+	return f(b)
+}
+
+// Sinks - kilka odbiorników; pierwszy błąd przerywa zapis
+// ================================================================================================
+type Sinks []Sink
+
+// Write - zapis do wszystkich odbiorników po kolei
+func (s Sinks) Write(b *Batch) error {
+	for _, sink := range s {
+		if err := sink.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonSink - wartości symboli jako linie json
+// ================================================================================================
+type jsonSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONSink - odbiornik zapisujący linię {"symbol":...,"v":...,"q":...,"t":...} na symbol
+// ================================================================================================
+func NewJSONSink(w io.Writer) Sink {
+	return &jsonSink{w: w}
+}
+
+// Write - zapis wartości symboli
+func (s *jsonSink) Write(b *Batch) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enc := json.NewEncoder(s.w)
+	for _, v := range b.Symbols {
+		line := struct {
+			Symbol  string      `json:"symbol"`
+			V       interface{} `json:"v"`
+			Quality bool        `json:"q"`
+			T       int64       `json:"t"`
+		}{v.Symbol, v.Value, v.Quality, v.Time.UnixNano() / int64(time.Millisecond)}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
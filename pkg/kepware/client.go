@@ -0,0 +1,279 @@
+// Package kepware konfiguruje KEPServerEX przez Configuration REST API (/config/v1/project/...):
+// kanał, urządzenie, tagi i elementy agentów IoT Gateway wygenerowane przez tagsgen.
+package kepware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// ErrAPI - błąd odpowiedzi Configuration API
+// ================================================================================================
+var ErrAPI = errors.New("Kepware Configuration API error")
+
+// Rodzaje zmian konfiguracji
+// ================================================================================================
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Action - zmiana jednego obiektu konfiguracji (wykonana lub planowana w trybie DryRun)
+// ================================================================================================
+type Action struct {
+	Type string
+	Path string
+	Name string
+}
+
+func (a Action) String() string {
+	return fmt.Sprintf("%s %s/%s", a.Type, a.Path, a.Name)
+}
+
+// Client - klient Configuration API jednego serwera KEPServerEX
+// ================================================================================================
+type Client struct {
+	BaseURL  string
+	User     string
+	Password string
+
+	// DryRun - zmiany są tylko planowane (odczyt konfiguracji bez zapisu)
+	DryRun bool
+
+	HTTP *http.Client
+}
+
+// NewClient - klient dla adresu serwera, np. http://localhost:57412
+// ================================================================================================
+func NewClient(baseURL string, user string, password string) *Client {
+	return &Client{
+		BaseURL:  strings.TrimSuffix(baseURL, "/"),
+		User:     user,
+		Password: password,
+		HTTP:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// ProjectPath - ścieżka obiektu projektu z nazw kolejnych poziomów, np. "channels", "Kanał"
+// ================================================================================================
+func ProjectPath(elems ...string) string {
+	path := "/config/v1/project"
+	for _, e := range elems {
+		path += "/" + url.PathEscape(e)
+	}
+	return path
+}
+
+// do - zapytanie do API; odpowiedź json zapisywana do out (może być nil)
+// ================================================================================================
+func (c *Client) do(method string, path string, body interface{}, out interface{}) (int, error) {
+
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, r)
+	if err != nil {
+		return 0, err
+	}
+	req.SetBasicAuth(c.User, c.Password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodGet {
+		return resp.StatusCode, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := strings.TrimSpace(string(data))
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			msg = apiErr.Message
+		}
+		return resp.StatusCode, fmt.Errorf("%w: %s %s: %d %s", ErrAPI, method, path, resp.StatusCode, msg)
+	}
+
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("%w: %s %s: %v", ErrAPI, method, path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// Get - obiekt lub lista obiektów; false, gdy nie istnieje
+// ================================================================================================
+func (c *Client) Get(path string, out interface{}) (bool, error) {
+	status, err := c.do(http.MethodGet, path, nil, out)
+	return status != http.StatusNotFound && err == nil, err
+}
+
+// List - obiekty kolekcji wg nazwy
+// ================================================================================================
+func (c *Client) List(path string) (map[string]tagsgen.KepObject, error) {
+
+	var list []tagsgen.KepObject
+	found, err := c.Get(path, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string]tagsgen.KepObject)
+	if found {
+		for _, o := range list {
+			objects[o.Name()] = o
+		}
+	}
+	return objects, nil
+}
+
+// properties - właściwości obiektu bez obiektów podrzędnych (listy "devices", "tags", ...)
+// ================================================================================================
+func properties(o tagsgen.KepObject) tagsgen.KepObject {
+	props := make(tagsgen.KepObject)
+	for key, value := range o {
+		if strings.Contains(key, ".") {
+			props[key] = value
+		}
+	}
+	return props
+}
+
+// differs - czy istniejący obiekt ma inne wartości właściwości niż wymagane
+// ================================================================================================
+func differs(want tagsgen.KepObject, have tagsgen.KepObject) bool {
+	for key, value := range properties(want) {
+		a, _ := json.Marshal(value)
+		b, _ := json.Marshal(have[key])
+		if !bytes.Equal(a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ensure - utworzenie obiektu w kolekcji lub aktualizacja jego właściwości, gdy się różnią;
+// obiekty podrzędne nie są wysyłane
+// ================================================================================================
+func (c *Client) Ensure(collection string, want tagsgen.KepObject) (*Action, error) {
+
+	existing, err := c.List(collection)
+	if err != nil {
+		return nil, err
+	}
+	return c.ensure(collection, want, existing)
+}
+
+// ensure - utworzenie lub aktualizacja obiektu na podstawie listy istniejących obiektów
+// ================================================================================================
+func (c *Client) ensure(collection string, want tagsgen.KepObject, existing map[string]tagsgen.KepObject) (*Action, error) {
+
+	name := want.Name()
+	have, ok := existing[name]
+
+	switch {
+	case !ok:
+		a := &Action{Type: ActionCreate, Path: collection, Name: name}
+		if !c.DryRun {
+			if _, err := c.do(http.MethodPost, collection, properties(want), nil); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+
+	case differs(want, have):
+		a := &Action{Type: ActionUpdate, Path: collection, Name: name}
+		if !c.DryRun {
+			path := collection + "/" + url.PathEscape(name)
+
+			// PROJECT_ID zmienia się przy każdej zmianie projektu - aktualny z odczytu obiektu
+			var current tagsgen.KepObject
+			if _, err := c.Get(path, &current); err != nil {
+				return nil, err
+			}
+			body := properties(want)
+			if id, ok := current["PROJECT_ID"]; ok {
+				body["PROJECT_ID"] = id
+			}
+			if _, err := c.do(http.MethodPut, path, body, nil); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+
+	return nil, nil
+}
+
+// Sync - zgodność kolekcji z listą obiektów: brakujące są tworzone, różniące się aktualizowane,
+// a obiekty spoza listy, dla których stale zwraca true, usuwane
+// ================================================================================================
+func (c *Client) Sync(collection string, want []tagsgen.KepObject, stale func(tagsgen.KepObject) bool) (actions []Action, err error) {
+
+	existing, err := c.List(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, o := range want {
+		names[o.Name()] = true
+		a, err := c.ensure(collection, o, existing)
+		if err != nil {
+			return actions, err
+		}
+		if a != nil {
+			actions = append(actions, *a)
+		}
+	}
+
+	var obsolete []string
+	for name, o := range existing {
+		if !names[name] && stale != nil && stale(o) {
+			obsolete = append(obsolete, name)
+		}
+	}
+	sort.Strings(obsolete)
+
+	for _, name := range obsolete {
+		if !c.DryRun {
+			if _, err := c.do(http.MethodDelete, collection+"/"+url.PathEscape(name), nil, nil); err != nil {
+				return actions, err
+			}
+		}
+		actions = append(actions, Action{Type: ActionDelete, Path: collection, Name: name})
+	}
+
+	return actions, nil
+}
@@ -0,0 +1,156 @@
+package kepware
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// Deployment - konfiguracja jednego połączenia do wysłania na serwer
+// ================================================================================================
+type Deployment struct {
+	ConnectionName string
+
+	// Channel, Device - definicje kanału i urządzenia; nil - tylko nazwa i sterownik Siemens
+	Channel tagsgen.KepObject
+	Device  tagsgen.KepObject
+
+	// Tags - tagi urządzenia (tagsgen.ConfigTags z plc.csv)
+	Tags []tagsgen.KepObject
+
+	// Agent - agent IoT Gateway z elementami ("iot_items"); nil - bez agenta
+	Agent tagsgen.KepObject
+
+	// PreviousTags - nazwy tagów poprzedniej generacji (plc.csv); tagi z tej listy, których już nie
+	// ma w Tags, i ich elementy IoT Gateway są usuwane. Puste - nic nie jest usuwane, tagi
+	// założone ręcznie na serwerze nie są nigdy usuwane.
+	PreviousTags []string
+}
+
+// stale - nazwy tagów poprzedniej generacji, których nie ma w Tags
+// ================================================================================================
+func (d Deployment) stale() map[string]bool {
+
+	current := make(map[string]bool)
+	for _, t := range d.Tags {
+		current[t.Name()] = true
+	}
+
+	stale := make(map[string]bool)
+	for _, name := range d.PreviousTags {
+		if !current[name] {
+			stale[name] = true
+		}
+	}
+	return stale
+}
+
+// Children - obiekty podrzędne z listy obiektu ("devices", "tags", "iot_items")
+// ================================================================================================
+func Children(o tagsgen.KepObject, key string) ([]tagsgen.KepObject, error) {
+
+	switch list := o[key].(type) {
+	case nil:
+		return nil, nil
+	case []tagsgen.KepObject:
+		return list, nil
+	default:
+		// obiekt wczytany z pliku json
+		data, err := json.Marshal(list)
+		if err != nil {
+			return nil, err
+		}
+		var children []tagsgen.KepObject
+		err = json.Unmarshal(data, &children)
+		return children, err
+	}
+}
+
+// Deploy - utworzenie lub aktualizacja kanału, urządzenia, tagów i agenta IoT Gateway
+// ================================================================================================
+func (c *Client) Deploy(d Deployment) (actions []Action, err error) {
+
+	channelName, deviceName, err := tagsgen.SplitConnectionName(d.ConnectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	channel := d.Channel
+	if channel == nil {
+		channel = tagsgen.KepObject{
+			"common.ALLTYPES_NAME":                    channelName,
+			"servermain.MULTIPLE_TYPES_DEVICE_DRIVER": tagsgen.SiemensDriver,
+		}
+	}
+	device := d.Device
+	if device == nil {
+		device = tagsgen.KepObject{
+			"common.ALLTYPES_NAME":                    deviceName,
+			"servermain.MULTIPLE_TYPES_DEVICE_DRIVER": tagsgen.SiemensDriver,
+		}
+	}
+
+	add := func(a *Action) {
+		if a != nil {
+			actions = append(actions, *a)
+		}
+	}
+
+	// kanał i urządzenie
+	a, err := c.Ensure(ProjectPath("channels"), channel)
+	if err != nil {
+		return actions, err
+	}
+	add(a)
+
+	a, err = c.Ensure(ProjectPath("channels", channelName, "devices"), device)
+	if err != nil {
+		return actions, err
+	}
+	add(a)
+
+	// tagi urządzenia
+	stale := d.stale()
+	var staleTag func(tagsgen.KepObject) bool
+	if len(stale) > 0 {
+		staleTag = func(o tagsgen.KepObject) bool {
+			return stale[o.Name()]
+		}
+	}
+	list, err := c.Sync(ProjectPath("channels", channelName, "devices", deviceName, "tags"), d.Tags, staleTag)
+	actions = append(actions, list...)
+	if err != nil || d.Agent == nil {
+		return actions, err
+	}
+
+	// agent IoT Gateway i jego elementy
+	collection := tagsgen.AgentCollections[tagsgen.AgentType(d.Agent)]
+	if collection == "" {
+		collection = tagsgen.AgentCollections[tagsgen.AgentMQTT]
+	}
+	agentPath := ProjectPath("_iot_gateway", collection)
+
+	a, err = c.Ensure(agentPath, d.Agent)
+	if err != nil {
+		return actions, err
+	}
+	add(a)
+
+	items, err := Children(d.Agent, "iot_items")
+	if err != nil {
+		return actions, err
+	}
+
+	var staleItem func(tagsgen.KepObject) bool
+	if len(stale) > 0 {
+		prefix := d.ConnectionName + "."
+		staleItem = func(o tagsgen.KepObject) bool {
+			serverTag, _ := o["iot_gateway.IOT_ITEM_SERVER_TAG"].(string)
+			return strings.HasPrefix(serverTag, prefix) && stale[strings.TrimPrefix(serverTag, prefix)]
+		}
+	}
+	list, err = c.Sync(ProjectPath("_iot_gateway", collection, d.Agent.Name(), "iot_items"), items, staleItem)
+	actions = append(actions, list...)
+	return actions, err
+}
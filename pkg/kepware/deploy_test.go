@@ -0,0 +1,217 @@
+package kepware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// fakeServer - Configuration API w pamięci: kolekcja -> nazwa -> właściwości obiektu
+// ================================================================================================
+type fakeServer struct {
+	mu      sync.Mutex
+	objects map[string]map[string]tagsgen.KepObject
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{objects: make(map[string]map[string]tagsgen.KepObject)}
+}
+
+// put - obiekt w kolekcji
+// ================================================================================================
+func (f *fakeServer) put(collection string, o tagsgen.KepObject) {
+	if f.objects[collection] == nil {
+		f.objects[collection] = make(map[string]tagsgen.KepObject)
+	}
+	f.objects[collection][o.Name()] = o
+}
+
+// names - posortowane nazwy obiektów kolekcji
+// ================================================================================================
+func (f *fakeServer) names(collection string) (names []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for name := range f.objects[collection] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := r.URL.Path
+	parent, name := path.Dir(p), path.Base(p)
+
+	switch r.Method {
+	case http.MethodGet:
+		if list, ok := f.objects[p]; ok {
+			out := []tagsgen.KepObject{}
+			for _, o := range list {
+				out = append(out, o)
+			}
+			json.NewEncoder(w).Encode(out)
+			return
+		}
+		if o, ok := f.objects[parent][name]; ok {
+			out := tagsgen.KepObject{"PROJECT_ID": 1}
+			for k, v := range o {
+				out[k] = v
+			}
+			json.NewEncoder(w).Encode(out)
+			return
+		}
+		http.NotFound(w, r)
+
+	case http.MethodPost, http.MethodPut:
+		var o tagsgen.KepObject
+		if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		delete(o, "PROJECT_ID")
+		if r.Method == http.MethodPost {
+			f.put(p, o)
+		} else {
+			f.put(parent, o)
+		}
+
+	case http.MethodDelete:
+		if _, ok := f.objects[parent][name]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(f.objects[parent], name)
+	}
+}
+
+// countActions - liczba zmian wg rodzaju
+// ================================================================================================
+func countActions(actions []Action) map[string]int {
+	counts := make(map[string]int)
+	for _, a := range actions {
+		counts[a.Type]++
+	}
+	return counts
+}
+
+// agent - agent MQTT z elementami dla tagów
+// ================================================================================================
+func agent(tags []tagsgen.KepObject) tagsgen.KepObject {
+	var items []tagsgen.KepObject
+	for _, t := range tags {
+		serverTag := "SiemensTCPIP.PLC." + t.Name()
+		items = append(items, tagsgen.KepObject{
+			"common.ALLTYPES_NAME":            strings.ReplaceAll(serverTag, ".", "_"),
+			"iot_gateway.IOT_ITEM_SERVER_TAG": serverTag,
+		})
+	}
+	return tagsgen.KepObject{
+		"common.ALLTYPES_NAME":        "tagsgenerator",
+		"iot_gateway.MQTT_CLIENT_URL": "tcp://localhost:1883",
+		"iot_items":                   items,
+	}
+}
+
+func TestDeploy(t *testing.T) {
+
+	f := newFakeServer()
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "Administrator", "")
+	tagsPath := ProjectPath("channels", "SiemensTCPIP", "devices", "PLC", "tags")
+	itemsPath := ProjectPath("_iot_gateway", "mqtt_clients", "tagsgenerator", "iot_items")
+
+	tags := tagsgen.ConfigTags([]string{
+		`"tabMB_0","MB0[8]",Byte Array,1,RO,100,,,,,,,,,,"",`,
+		`"Speed","MW20",Word,1,RO,100,,,,,,,,,,"",`,
+	})
+	d := Deployment{ConnectionName: "SiemensTCPIP.PLC", Tags: tags, Agent: agent(tags)}
+
+	// utworzenie: kanał, urządzenie, 2 tagi, agent, 2 elementy
+	actions, err := c.Deploy(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countActions(actions)[ActionCreate]; n != 7 || len(actions) != 7 {
+		t.Fatalf("first deploy: %v", actions)
+	}
+	if got := f.names(tagsPath); strings.Join(got, ",") != "Speed,tabMB_0" {
+		t.Errorf("tags on server: %v", got)
+	}
+
+	// ponowne wdrożenie bez zmian
+	if actions, err = c.Deploy(d); err != nil || len(actions) != 0 {
+		t.Fatalf("second deploy: %v, %v", actions, err)
+	}
+
+	// aktualizacja adresu taga
+	tags[1]["servermain.TAG_ADDRESS"] = "MW22"
+	if actions, err = c.Deploy(d); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].Type != ActionUpdate || actions[0].Name != "Speed" {
+		t.Fatalf("update: %v", actions)
+	}
+
+	// tagi założone ręcznie nie są usuwane, nawet z przedrostkiem tab
+	f.mu.Lock()
+	f.put(tagsPath, tagsgen.KepObject{"common.ALLTYPES_NAME": "tabManual"})
+	f.put(tagsPath, tagsgen.KepObject{"common.ALLTYPES_NAME": "Manual"})
+	f.mu.Unlock()
+
+	// bez PreviousTags nic nie jest usuwane
+	d.Tags = tags[:1]
+	d.Agent = agent(d.Tags)
+	if actions, err = c.Deploy(d); err != nil || len(actions) != 0 {
+		t.Fatalf("deploy without previous tags: %v, %v", actions, err)
+	}
+
+	// usunięcie taga poprzedniej generacji (typowany tag Speed) i jego elementu IoT Gateway
+	d.PreviousTags = []string{"tabMB_0", "Speed", "tabMB_8"}
+	if actions, err = c.Deploy(d); err != nil {
+		t.Fatal(err)
+	}
+	if n := countActions(actions)[ActionDelete]; n != 2 || len(actions) != 2 {
+		t.Fatalf("delete: %v", actions)
+	}
+	if got := f.names(tagsPath); strings.Join(got, ",") != "Manual,tabMB_0,tabManual" {
+		t.Errorf("tags on server: %v", got)
+	}
+	if got := f.names(itemsPath); strings.Join(got, ",") != "SiemensTCPIP_PLC_tabMB_0" {
+		t.Errorf("IoT items on server: %v", got)
+	}
+}
+
+func TestDeployDryRun(t *testing.T) {
+
+	f := newFakeServer()
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "Administrator", "")
+	c.DryRun = true
+
+	d := Deployment{ConnectionName: "SiemensTCPIP.PLC", Tags: tagsgen.ConfigTags([]string{`"tabMB_0","MB0[8]",Byte Array,1,RO,100,,,,,,,,,,"",`})}
+	actions, err := c.Deploy(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 3 {
+		t.Errorf("planned actions: %v", actions)
+	}
+	if len(f.objects) != 0 {
+		t.Errorf("dry run modified the server: %v", f.objects)
+	}
+}
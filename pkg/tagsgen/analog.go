@@ -0,0 +1,199 @@
+package tagsgen
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Warunki alarmów analogowych: wartość taga wyzwalającego względem granicy
+// ================================================================================================
+const (
+	AnalogAbove        = "above"
+	AnalogBelow        = "below"
+	AnalogAboveOrEqual = "above_or_equal"
+	AnalogBelowOrEqual = "below_or_equal"
+	AnalogEqual        = "equal"
+	AnalogUnequal      = "unequal"
+)
+
+// Działanie histerezy alarmów analogowych
+// ================================================================================================
+const (
+	HysteresisComing = "coming" // przy przyjściu alarmu
+	HysteresisGoing  = "going"  // przy odejściu alarmu
+	HysteresisBoth   = "both"   // przy przyjściu i odejściu
+)
+
+// ErrInvalidAnalogAlarm - niepoprawna granica, warunek lub histereza alarmu analogowego
+// ================================================================================================
+var ErrInvalidAnalogAlarm = errors.New("invalid analog alarm")
+
+// AnalogAlarm - alarm analogowy: wartość taga wyzwalającego porównywana z granicą (stałą lub
+// wartością taga granicy) z histerezą
+// ================================================================================================
+type AnalogAlarm struct {
+	Trigger ValuePointer
+	Mode    string
+
+	// Limit - stała granica; LimitTag - tag z wartością granicy (zamiast Limit)
+	Limit    float64
+	LimitTag *ValuePointer `json:",omitempty"`
+
+	// Hysteresis - histereza w jednostkach wartości lub w % granicy (HysteresisPercent)
+	Hysteresis        float64 `json:",omitempty"`
+	HysteresisPercent bool    `json:",omitempty"`
+	HysteresisMode    string  `json:",omitempty"`
+}
+
+// ValuePointer - wartość taga HMI w tablicy tagów Kepware: typ S7 symbolu, typ danych taga
+// Kepware (Byte Array dla bloków) i rozmiar w bajtach
+// ================================================================================================
+type ValuePointer struct {
+	Tag            string
+	TagName        string
+	Index          int
+	SymbolDataType string
+	DataType       string
+	Size           int
+}
+
+// analogModes - nazwy warunków w eksportach WinCC flexible i TIA Portal
+// ================================================================================================
+var analogModes = map[string]string{
+	"above":            AnalogAbove,
+	"higher":           AnalogAbove,
+	"high limit":       AnalogAbove,
+	"upper limit":      AnalogAbove,
+	"on rising edge":   AnalogAbove,
+	"rising edge":      AnalogAbove,
+	"below":            AnalogBelow,
+	"lower":            AnalogBelow,
+	"low limit":        AnalogBelow,
+	"lower limit":      AnalogBelow,
+	"on falling edge":  AnalogBelow,
+	"falling edge":     AnalogBelow,
+	"higher or equal":  AnalogAboveOrEqual,
+	"greater or equal": AnalogAboveOrEqual,
+	"above or equal":   AnalogAboveOrEqual,
+	"lower or equal":   AnalogBelowOrEqual,
+	"less or equal":    AnalogBelowOrEqual,
+	"below or equal":   AnalogBelowOrEqual,
+	"equal":            AnalogEqual,
+	"unequal":          AnalogUnequal,
+	"not equal":        AnalogUnequal,
+}
+
+// hysteresisModes - nazwy działania histerezy w eksportach
+// ================================================================================================
+var hysteresisModes = map[string]string{
+	"":                       "",
+	"off":                    "",
+	"none":                   "",
+	"coming":                 HysteresisComing,
+	"on coming":              HysteresisComing,
+	"going":                  HysteresisGoing,
+	"on going":               HysteresisGoing,
+	"both":                   HysteresisBoth,
+	"coming and going":       HysteresisBoth,
+	"on coming and going":    HysteresisBoth,
+	"on coming and on going": HysteresisBoth,
+}
+
+// analogAlarmDef - warunek, granica i histereza alarmu analogowego z kolumn eksportu
+// ================================================================================================
+func analogAlarmDef(def *AlarmDef, limit string, mode string, hysteresis string, percent string, hysteresisMode string) error {
+
+	key := strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(mode, "_", " ")), " "))
+	if key == "" {
+		return fmt.Errorf("%w: no limit mode", ErrInvalidAnalogAlarm)
+	}
+	m, ok := analogModes[key]
+	if !ok {
+		return fmt.Errorf("%w: limit mode %q", ErrInvalidAnalogAlarm, mode)
+	}
+
+	key = strings.ToLower(strings.Join(strings.Fields(hysteresisMode), " "))
+	hm, ok := hysteresisModes[key]
+	if !ok {
+		return fmt.Errorf("%w: hysteresis mode %q", ErrInvalidAnalogAlarm, hysteresisMode)
+	}
+
+	var h float64
+	if s := strings.TrimSpace(strings.ReplaceAll(hysteresis, ",", ".")); s != "" {
+		var err error
+		if h, err = strconv.ParseFloat(s, 64); err != nil || h < 0 {
+			return fmt.Errorf("%w: hysteresis %q", ErrInvalidAnalogAlarm, hysteresis)
+		}
+	}
+
+	limit = strings.Trim(strings.TrimSpace(limit), "\"")
+	if limit == "" {
+		return fmt.Errorf("%w: no limit", ErrInvalidAnalogAlarm)
+	}
+
+	def.Analog = true
+	def.LimitMode = m
+	def.Limit = limit
+	def.Hysteresis = h
+	def.HysteresisMode = hm
+	switch strings.ToLower(strings.TrimSpace(percent)) {
+	case "1", "yes", "true", "on", "x":
+		def.HysteresisPercent = true
+	}
+	return nil
+}
+
+// resolveValuePointer - wartość symbolu w tablicy tagów (blok lub tag z typem danych)
+// ================================================================================================
+func (p *Project) resolveValuePointer(symbolName string) (*ValuePointer, error) {
+
+	tagName, index, err := p.resolveSymbolPointer(symbolName)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &ValuePointer{Tag: symbolName, TagName: tagName, Index: index, DataType: kepByteArray}
+	for _, sym := range p.Symbols {
+		if sym.Name == symbolName {
+			v.SymbolDataType = sym.DataType
+			v.Size, _ = strconv.Atoi(sym.Size)
+			if t, ok := p.symbolKepTag(sym); ok {
+				v.DataType = t.DataType
+			}
+			break
+		}
+	}
+	return v, nil
+}
+
+// resolveAnalogAlarm - tag wyzwalający i granica alarmu analogowego; błąd, gdy brak granicy
+// lub warunku albo tagu wyzwalającego lub taga granicy nie ma w tagach Kepware
+// ================================================================================================
+func (p *Project) resolveAnalogAlarm(def AlarmDef) (*AnalogAlarm, error) {
+
+	if def.Limit == "" || def.LimitMode == "" {
+		return nil, fmt.Errorf("%w: no limit or limit mode", ErrInvalidAnalogAlarm)
+	}
+
+	trigger, err := p.resolveValuePointer(def.TriggerTag)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AnalogAlarm{
+		Trigger:           *trigger,
+		Mode:              def.LimitMode,
+		Hysteresis:        def.Hysteresis,
+		HysteresisPercent: def.HysteresisPercent,
+		HysteresisMode:    def.HysteresisMode,
+	}
+
+	if limit, err := strconv.ParseFloat(strings.ReplaceAll(def.Limit, ",", "."), 64); err == nil {
+		a.Limit = limit
+	} else if a.LimitTag, err = p.resolveValuePointer(def.Limit); err != nil {
+		return nil, fmt.Errorf("limit tag %s: %w", def.Limit, err)
+	}
+	return a, nil
+}
@@ -0,0 +1,262 @@
+package tagsgen
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Rodzaje zmian bloków względem poprzedniej generacji
+// ================================================================================================
+const (
+	BlockAdded   = "added"
+	BlockResized = "resized"
+	BlockRemoved = "removed"
+)
+
+// BlockChange - zmiana bloku (tagu Byte Array) względem poprzedniej generacji
+// Zmiany resized i removed psują subskrypcje zbudowane na poprzednim iot.csv.
+// ================================================================================================
+type BlockChange struct {
+	Name    string
+	Change  string
+	OldSize int
+	NewSize int
+}
+
+// plcBlockAddress - adres bloku w plc.csv: "IB0[8]", "DB100.DBB26[32]"
+// tagsBlockName - nazwa bloku w tags.json: "tabIB_0", "tabDB100_26"
+// ================================================================================================
+var (
+	plcBlockAddress = regexp.MustCompile(`^(IB|MB|QB|DB\d+)(?:\.DBB)?(\d+)\[(\d+)\]$`)
+	tagsBlockName   = regexp.MustCompile(`^tab(IB|MB|QB|DB\d+)_(\d+)$`)
+)
+
+// LoadBaselinePLC - wczytanie bloków z poprzedniego pliku plc.csv
+// ================================================================================================
+func (p *Project) LoadBaselinePLC(r io.Reader) error {
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	for _, fields := range records {
+		if len(fields) < 3 || fields[2] != kepByteArray {
+			continue
+		}
+
+		m := plcBlockAddress.FindStringSubmatch(fields[1])
+		if m == nil {
+			continue
+		}
+		start, _ := strconv.Atoi(m[2])
+		size, _ := strconv.Atoi(m[3])
+
+		p.Baseline = append(p.Baseline, KepTag{
+			Name:          fields[0],
+			Type:          m[1],
+			DataType:      kepByteArray,
+			StartingIndex: start,
+			Size:          size,
+		})
+	}
+
+	return nil
+}
+
+// LoadBaselineTags - odtworzenie bloków z poprzedniego pliku tags.json
+// Rozmiar bloku to koniec ostatniego symbolu w bloku (plc.csv zawiera dokładny rozmiar).
+// ================================================================================================
+func (p *Project) LoadBaselineTags(r io.Reader) error {
+
+	var tags Tags
+	if err := json.NewDecoder(r).Decode(&tags); err != nil {
+		return err
+	}
+
+	blocks := make(map[string]int)
+	var names []string
+
+	for _, t := range tags.Tags {
+		if t.DataType != "" && t.DataType != kepByteArray {
+			continue
+		}
+		if tagsBlockName.FindStringSubmatch(t.TagName) == nil {
+			continue
+		}
+
+		size := t.Size
+		if size < 1 {
+			size = 1
+		}
+		if _, ok := blocks[t.TagName]; !ok {
+			names = append(names, t.TagName)
+		}
+		if t.Index+size > blocks[t.TagName] {
+			blocks[t.TagName] = t.Index + size
+		}
+	}
+
+	for _, name := range names {
+		m := tagsBlockName.FindStringSubmatch(name)
+		start, _ := strconv.Atoi(m[2])
+
+		p.Baseline = append(p.Baseline, KepTag{
+			Name:          name,
+			Type:          m[1],
+			DataType:      kepByteArray,
+			StartingIndex: start,
+			Size:          blocks[name],
+		})
+	}
+
+	return nil
+}
+
+// planStableBlocks - podział obrazu z zachowaniem granic bloków poprzedniej generacji
+// Blok z poprzedniej generacji zostaje, jeśli zawiera jeszcze jakiś wpis; jest powiększany, gdy
+// wpis wychodzi poza jego koniec (do rozmiaru maksymalnego - większy blok jest dzielony od nowa).
+// Blok, którego początek leży w powiększonym poprzednim bloku lub wewnątrz wpisu zaczynającego się
+// przed nim, też jest dzielony od nowa - bloki nigdy nie odczytują tych samych bajtów.
+// Wpisy spoza zachowanych bloków dzielone są jak zwykle w przerwach pomiędzy nimi, a nowe bloki
+// dopisywane są za zachowanymi.
+// ================================================================================================
+func (p *Project) planStableBlocks(image *memImage, name string, opts Options) (spans []blockSpan) {
+
+	limit := opts.blockLimit()
+	entries := imageEntries(image)
+	residual := *image
+
+	type candidate struct {
+		span   blockSpan
+		inside []blockSpan
+	}
+	var candidates []candidate
+
+	for _, b := range p.Baseline {
+		if b.Type != name || b.DataType != kepByteArray {
+			continue
+		}
+
+		span := blockSpan{start: b.StartingIndex, size: b.Size}
+		end := span.start + span.size
+		var inside []blockSpan
+		for _, e := range entries {
+			if e.start >= span.start && e.start < span.start+span.size {
+				inside = append(inside, e)
+				if e.start+e.size > end {
+					end = e.start + e.size
+				}
+			}
+		}
+		if len(inside) == 0 || (end-span.start > span.size && end-span.start > limit) {
+			continue
+		}
+
+		span.size = end - span.start
+		candidates = append(candidates, candidate{span: span, inside: inside})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].span.start < candidates[j].span.start })
+
+	covered := 0
+	for _, c := range candidates {
+		if c.span.start < covered {
+			continue
+		}
+		crossed := false
+		for _, e := range entries {
+			if e.start < c.span.start && e.start+e.size > c.span.start {
+				crossed = true
+				break
+			}
+		}
+		if crossed {
+			continue
+		}
+
+		spans = append(spans, c.span)
+		covered = c.span.start + c.span.size
+		for _, e := range c.inside {
+			residual[e.start] = 0
+		}
+	}
+
+	// nowe wpisy - w przerwach pomiędzy zachowanymi blokami; przy optymalizacji koszt podziału
+	// przerw algorytmem zachłannym do porównania w p.BlockReport
+	var free memImage
+	lo := 0
+	kept := len(spans)
+	greedy := spansCost(spans)
+	for i := 0; i <= kept; i++ {
+		hi := len(image)
+		if i < kept {
+			hi = spans[i].start
+		}
+		if lo < hi {
+			copy(free[lo:hi], residual[lo:hi])
+			if opts.Optimize {
+				spans = append(spans, optimizeImageBlocks(&free, limit, opts.RequestCost, opts.ByteCost)...)
+				c := spansCost(planImageBlocks(&free, limit, opts.GapTolerance))
+				greedy.Requests += c.Requests
+				greedy.Bytes += c.Bytes
+			} else {
+				spans = append(spans, planImageBlocks(&free, limit, opts.GapTolerance)...)
+			}
+			for j := lo; j < hi; j++ {
+				free[j] = 0
+			}
+		}
+		if i < kept && spans[i].start+spans[i].size > lo {
+			lo = spans[i].start + spans[i].size
+		}
+	}
+
+	// nowe bloki za zachowanymi - symbole na styku wskazują nadal na zachowany blok
+	added := spans[kept:]
+	sort.Slice(added, func(i, j int) bool { return added[i].start < added[j].start })
+
+	if opts.Optimize && len(spans) > 0 {
+		p.BlockReport = append(p.BlockReport, BlockStats{Name: name, Greedy: greedy, Optimized: spansCost(spans)})
+	}
+	return
+}
+
+// compareBaseline - zmiany bloków względem poprzedniej generacji
+// ================================================================================================
+func (p *Project) compareBaseline() {
+
+	p.BlockChanges = nil
+
+	current := make(map[string]KepTag)
+	for _, t := range p.KepTags {
+		if t.DataType == kepByteArray {
+			current[t.Name] = t
+		}
+	}
+
+	previous := make(map[string]KepTag)
+	for _, b := range p.Baseline {
+		previous[b.Name] = b
+
+		t, ok := current[b.Name]
+		if !ok {
+			p.BlockChanges = append(p.BlockChanges, BlockChange{Name: b.Name, Change: BlockRemoved, OldSize: b.Size})
+		} else if t.Size != b.Size {
+			p.BlockChanges = append(p.BlockChanges, BlockChange{Name: b.Name, Change: BlockResized, OldSize: b.Size, NewSize: t.Size})
+		}
+	}
+
+	for _, t := range p.KepTags {
+		if _, ok := previous[t.Name]; t.DataType == kepByteArray && !ok {
+			p.BlockChanges = append(p.BlockChanges, BlockChange{Name: t.Name, Change: BlockAdded, NewSize: t.Size})
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package tagsgen
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// merker - symbol w obszarze M (MB, MW, MD)
+// ================================================================================================
+func merker(name string, periph string, address string) Symbol {
+	size := map[string]string{"MB": "1", "MW": "2", "MD": "4"}[periph]
+	return Symbol{Name: name, Periph: periph, AddressHi: address, Size: size}
+}
+
+// blocks - bloki Byte Array projektu: nazwa -> [początek, koniec)
+// ================================================================================================
+func blocks(t *testing.T, p *Project) map[string][2]int {
+	t.Helper()
+
+	list := make(map[string][2]int)
+	var spans [][2]int
+	for _, k := range p.KepTags {
+		if k.DataType == kepByteArray {
+			list[k.Name] = [2]int{k.StartingIndex, k.StartingIndex + k.Size}
+			spans = append(spans, list[k.Name])
+		}
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	for i := 1; i < len(spans); i++ {
+		if spans[i][0] < spans[i-1][1] {
+			t.Errorf("overlapping blocks %v and %v", spans[i-1], spans[i])
+		}
+	}
+	return list
+}
+
+func TestStableBlocksDoNotOverlap(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		baseline []KepTag
+		symbols  []Symbol
+		kept     []string
+	}{
+		{
+			// MD2 powiększa tabMB_0 poza początek tabMB_4
+			name: "kept block grows into next",
+			baseline: []KepTag{
+				{Name: "tabMB_0", Type: "MB", DataType: kepByteArray, StartingIndex: 0, Size: 4},
+				{Name: "tabMB_4", Type: "MB", DataType: kepByteArray, StartingIndex: 4, Size: 4},
+			},
+			symbols: []Symbol{merker("A", "MD", "2"), merker("B", "MB", "6")},
+			kept:    []string{"tabMB_0"},
+		},
+		{
+			// nowy MD2 zaczyna się przed tabMB_4 i kończy w nim
+			name: "new entry crosses kept block",
+			baseline: []KepTag{
+				{Name: "tabMB_4", Type: "MB", DataType: kepByteArray, StartingIndex: 4, Size: 4},
+			},
+			symbols: []Symbol{merker("A", "MD", "2"), merker("B", "MB", "6")},
+		},
+		{
+			name: "blocks kept",
+			baseline: []KepTag{
+				{Name: "tabMB_0", Type: "MB", DataType: kepByteArray, StartingIndex: 0, Size: 4},
+				{Name: "tabMB_4", Type: "MB", DataType: kepByteArray, StartingIndex: 4, Size: 4},
+			},
+			symbols: []Symbol{merker("A", "MW", "0"), merker("B", "MB", "6")},
+			kept:    []string{"tabMB_0", "tabMB_4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProject()
+			p.Symbols = tt.symbols
+			p.Baseline = tt.baseline
+
+			opts := DefaultOptions()
+			opts.BlockSize = 16
+			if _, err := p.Generate(opts); err != nil {
+				t.Fatal(err)
+			}
+
+			got := blocks(t, p)
+			for _, name := range tt.kept {
+				if _, ok := got[name]; !ok {
+					t.Errorf("block %s not kept: %v", name, got)
+				}
+			}
+
+			// każdy symbol leży w całości w jednym bloku
+			for _, sym := range p.Symbols {
+				tagName, index, err := p.resolveSymbolPointer(sym.Name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				size := map[string]int{"MB": 1, "MW": 2, "MD": 4}[sym.Periph]
+				if b := got[tagName]; b[0]+index+size > b[1] {
+					t.Errorf("symbol %s at %s[%d] outside block %v", sym.Name, tagName, index, b)
+				}
+			}
+		})
+	}
+}
+
+func TestBaselineOptimizeReport(t *testing.T) {
+
+	p := NewProject()
+	p.Symbols = []Symbol{merker("A", "MW", "0"), merker("B", "MB", "20"), merker("C", "MB", "23")}
+	p.Baseline = []KepTag{{Name: "tabMB_0", Type: "MB", DataType: kepByteArray, StartingIndex: 0, Size: 2}}
+
+	opts := DefaultOptions()
+	opts.Optimize = true
+	res, err := p.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.BlockReport) != 1 || res.BlockReport[0].Name != "MB" {
+		t.Fatalf("block report = %+v", res.BlockReport)
+	}
+	if r := res.BlockReport[0]; r.Optimized.Requests != 2 || r.Greedy.Requests != 3 {
+		t.Errorf("block report = %+v", r)
+	}
+}
+
+func TestBaselineSymbolsMode(t *testing.T) {
+
+	p := NewProject()
+	p.Symbols = []Symbol{merker("A", "MW", "0")}
+	p.Baseline = []KepTag{{Name: "tabMB_0", Type: "MB", DataType: kepByteArray, StartingIndex: 0, Size: 2}}
+
+	opts := DefaultOptions()
+	opts.Mode = ModeSymbols
+	res, err := p.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range res.BlockChanges {
+		if strings.HasPrefix(c.Name, "tab") {
+			t.Errorf("block change in symbols mode: %+v", c)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package tagsgen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// s7ReadOverhead - nagłówki odpowiedzi S7 Read Var w PDU (PDU 240 -> 222 bajty danych)
+// ================================================================================================
+const s7ReadOverhead = 18
+
+// blockSpan - zakres bajtów jednego bloku (tagu Byte Array)
+// ================================================================================================
+type blockSpan struct {
+	start, size int
+}
+
+// blockLimit - maksymalny rozmiar bloku: -b ograniczony danymi mieszczącymi się w PDU
+// ================================================================================================
+func (o Options) blockLimit() int {
+	limit := o.BlockSize
+	if o.PDUSize > 0 {
+		payload := o.PDUSize - s7ReadOverhead
+		if limit <= 0 || limit > payload {
+			limit = payload
+		}
+	}
+	return limit
+}
+
+// planImageBlocks - podział obrazu zajętości na bloki nie większe niż maxSize
+// Bloki oddzielone co najwyżej gap wolnymi bajtami są łączone w jeden.
+// Wpis większy od maxSize (np. STRING) dostaje własny blok.
+// ================================================================================================
+func planImageBlocks(image *memImage, maxSize int, gap int) (spans []blockSpan) {
+
+	for ptr := 0; ptr < len(image); ptr++ {
+		if image[ptr] == 0 {
+			continue
+		}
+
+		size := int(image[ptr])
+		for ptr+size < len(image) {
+			// następny zajęty bajt w granicy tolerancji przerwy
+			next := ptr + size
+			for next < len(image) && next-ptr-size < gap && image[next] == 0 {
+				next++
+			}
+			if next >= len(image) || image[next] == 0 {
+				break
+			}
+			end := next + int(image[next])
+			if end-ptr > maxSize {
+				break
+			}
+			size = end - ptr
+		}
+
+		spans = append(spans, blockSpan{start: ptr, size: size})
+		ptr += size - 1
+	}
+
+	return
+}
+
+// prepPLCImageBlocks - wygenerowanie listy tagów w blokach na podstawie obrazu zajętości
+// plc = p.prepPLCImageBlocks(&iImage, "IB", opts)
+// ================================================================================================
+func (p *Project) prepPLCImageBlocks(image *memImage, name string, opts Options) (outLines []string) {
+
+	for _, span := range p.planBlocks(image, name, opts) {
+		var line string
+
+		var newTag KepTag
+
+		newTag.Name = fmt.Sprintf("tab%s_%d", name, span.start)
+		newTag.Type = name
+		newTag.DataType = kepByteArray
+		newTag.StartingIndex = span.start
+		newTag.Size = span.size
+
+		p.KepTags = append(p.KepTags, newTag)
+
+		if !strings.Contains(name, "DB") {
+			line = fmt.Sprintf("\"tab%s_%d\",\"%s%d[%d]\",Byte Array,1,RO,%d,,,,,,,,,,\"\",", name, span.start, name, span.start, span.size, opts.ScanRate)
+		} else {
+			line = fmt.Sprintf("\"tab%s_%d\",\"%s.DBB%d[%d]\",Byte Array,1,RO,%d,,,,,,,,,,\"\",", name, span.start, name, span.start, span.size, opts.ScanRate)
+		}
+		outLines = append(outLines, line)
+	}
+
+	return
+}
+
+// addSymToDBImage
+// ================================================================================================
+func (p *Project) addSymToDBImage(sym Symbol) {
+
+	nr, err := strconv.Atoi(sym.Nr)
+	if err == nil {
+		found := false
+
+		var index int
+
+		for i, block := range p.DBBlocks {
+			if block.Nr == nr {
+				found = true
+				index = i
+				break
+			}
+		}
+
+		var adr int
+		n, err := fmt.Sscanf(sym.AddressHi, "%d", &adr)
+		if err == nil && n > 0 {
+
+			// fmt.Println(sym.Type)
+			var size int
+			si, err := strconv.Atoi(sym.Size)
+			if err == nil && si > 1 {
+				size = si
+			} else {
+				if sym.Type == "DBX" {
+					size = 1
+				}
+				if sym.Type == "DBB" {
+					size = 1
+				}
+				if sym.Type == "DBW" {
+					size = 2
+				}
+				if sym.Type == "DBD" {
+					size = 4
+				}
+			}
+
+			// if size > 0 {
+
+			if !found {
+				var newBlock DBBlock
+				newBlock.Nr = nr
+				p.DBBlocks = append(p.DBBlocks, newBlock)
+				index = len(p.DBBlocks) - 1
+				// fmt.Println("Nowy blok " + sym.Periph)
+			}
+
+			// fmt.Println("Adres DB" + sym.Nr + "." + sym.AddressHi)
+			p.DBBlocks[index].Tab[adr] = size
+			// }
+		}
+	}
+}
+
+// generatePLC - funkcja generująca plik plc
+// "Inputs","IB0[64]",Byte Array,1,R/W,100,,,,,,,,,,"",
+// "Merkers","MB0[32]",Byte Array,1,R/W,100,,,,,,,,,,"",
+// "Outputs","QB0[32]",Byte Array,1,R/W,100,,,,,,,,,,"",
+// ================================================================================================
+func (p *Project) generatePLC(opts Options) (plc []string) {
+
+	mode := opts.Mode
+	freq := opts.ScanRate
+
+	plc = append(plc, "Tag Name,Address,Data Type,Respect Data Type,Client Access,Scan Rate,Scaling,Raw Low,Raw High,Scaled Low,Scaled High,Scaled Data Type,Clamp Low,Clamp High,Eng Units,Description,Negate Value")
+
+	var iImage memImage
+	var mImage memImage
+	var oImage memImage
+
+	// Wypełnienie obrazów
+	for _, sym := range p.Symbols {
+
+		// W trybie hybrydowym wartości analogowe nie trafiają do bloków
+		if mode == ModeHybrid && isStandalone(sym) {
+			continue
+		}
+
+		if len(sym.AddressHi) > 0 {
+			byteNr, err := strconv.Atoi(sym.AddressHi)
+
+			if err == nil && byteNr >= 0 && byteNr < 65536 {
+				if sym.Periph == "I" || sym.Periph == "IB" {
+					iImage[byteNr] = 1
+				}
+				if sym.Periph == "IW" {
+					iImage[byteNr] = 2
+				}
+				if sym.Periph == "ID" {
+					iImage[byteNr] = 4
+				}
+
+				if sym.Periph == "M" || sym.Periph == "MB" {
+					mImage[byteNr] = 1
+				}
+				if sym.Periph == "MW" {
+					mImage[byteNr] = 2
+				}
+				if sym.Periph == "MD" {
+					mImage[byteNr] = 4
+				}
+
+				if sym.Periph == "Q" || sym.Periph == "QB" {
+					oImage[byteNr] = 1
+				}
+				if sym.Periph == "QW" {
+					oImage[byteNr] = 2
+				}
+				if sym.Periph == "QD" {
+					oImage[byteNr] = 4
+				}
+
+				if strings.Contains(sym.Periph, "DB") {
+					p.addSymToDBImage(sym)
+				}
+
+			}
+		}
+	}
+
+	// Jeden tag na symbol
+	if mode == ModeSymbols {
+		plc = append(plc, p.prepPLCSymbolTags(freq, false)...)
+		return
+	}
+
+	// Pakowanie w bloki
+	plc = append(plc, p.prepPLCImageBlocks(&iImage, "IB", opts)...)
+	plc = append(plc, p.prepPLCImageBlocks(&mImage, "MB", opts)...)
+	plc = append(plc, p.prepPLCImageBlocks(&oImage, "QB", opts)...)
+
+	for i := range p.DBBlocks {
+		plc = append(plc, p.prepPLCImageBlocks(&p.DBBlocks[i].Tab, "DB"+strconv.Itoa(p.DBBlocks[i].Nr), opts)...)
+	}
+
+	// Tagi z typem danych dla wartości analogowych
+	if mode == ModeHybrid {
+		plc = append(plc, p.prepPLCSymbolTags(freq, true)...)
+	}
+
+	return
+}
@@ -0,0 +1,121 @@
+package tagsgen
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestLargeArrayInDBBlock(t *testing.T) {
+
+	for _, words := range []int{128, 200} {
+		p := NewProject()
+		size := words * 2
+		p.Symbols = []Symbol{
+			{Name: "Before", Periph: "DB10", Nr: "10", AddressHi: "0", Type: "DBW", Size: "2", DataType: "WORD"},
+			{Name: "Words", Periph: "DB10", Nr: "10", AddressHi: "2", Type: "DBW", Size: strconv.Itoa(size), DataType: "WORD"},
+			{Name: "After", Periph: "DB10", Nr: "10", AddressHi: strconv.Itoa(2 + size), Type: "DBW", Size: "2", DataType: "WORD"},
+		}
+		if _, err := p.Generate(DefaultOptions()); err != nil {
+			t.Fatal(err)
+		}
+
+		got := blocks(t, p)
+		tagName, index, err := p.resolveSymbolPointer("Words")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := got[tagName]; index != 0 || b != [2]int{2, 2 + size} {
+			t.Errorf("%d words: array in %s[%d] %v, want own block [2, %d)", words, tagName, index, b, 2+size)
+		}
+		if tagName, _, err := p.resolveSymbolPointer("After"); err != nil || got[tagName][0] != 2+size {
+			t.Errorf("%d words: After in %s %v, %v", words, tagName, got[tagName], err)
+		}
+	}
+}
+
+func TestArraySize(t *testing.T) {
+
+	tests := []struct {
+		size     string
+		dataType string
+		length   int
+		count    int
+		want     string
+	}{
+		{"2", "Int", 0, 0, "2"},
+		{"2", "Word", 0, 108, "216"},
+		{"4", "Real", 0, 200, "800"},
+		{"1", "String", 20, 0, "22"},
+		{"2", "Int", 0, 1, "2"},
+	}
+
+	for _, tt := range tests {
+		if got := arraySize(tt.size, tt.dataType, tt.length, tt.count); got != tt.want {
+			t.Errorf("arraySize(%s, %s, %d, %d) = %s, want %s", tt.size, tt.dataType, tt.length, tt.count, got, tt.want)
+		}
+	}
+}
+
+// testImage - obraz zajętości z wpisów {adres, rozmiar}
+// ================================================================================================
+func testImage(entries ...[2]int) *memImage {
+	image := new(memImage)
+	for _, e := range entries {
+		image[e[0]] = e[1]
+	}
+	return image
+}
+
+func TestBlockLimit(t *testing.T) {
+
+	tests := []struct {
+		blockSize, pdu int
+		want           int
+	}{
+		{128, 0, 128},
+		{0, 240, 222},
+		{128, 240, 128},
+		{512, 240, 222},
+		{512, 480, 462},
+		{512, 960, 512},
+	}
+
+	for _, tt := range tests {
+		opts := Options{BlockSize: tt.blockSize, PDUSize: tt.pdu}
+		if got := opts.blockLimit(); got != tt.want {
+			t.Errorf("blockLimit(-b %d, -pdu %d) = %d, want %d", tt.blockSize, tt.pdu, got, tt.want)
+		}
+	}
+}
+
+func TestPlanImageBlocks(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		image   *memImage
+		maxSize int
+		gap     int
+		want    []blockSpan
+	}{
+		{"empty", testImage(), 128, 0, nil},
+		{"adjacent", testImage([2]int{0, 2}, [2]int{2, 2}, [2]int{4, 4}), 128, 0, []blockSpan{{0, 8}}},
+		{"gap not merged", testImage([2]int{0, 2}, [2]int{4, 2}), 128, 0, []blockSpan{{0, 2}, {4, 2}}},
+		{"gap merged", testImage([2]int{0, 2}, [2]int{4, 2}), 128, 2, []blockSpan{{0, 6}}},
+		{"gap too wide", testImage([2]int{0, 2}, [2]int{5, 2}), 128, 2, []blockSpan{{0, 2}, {5, 2}}},
+		{"gaps chained", testImage([2]int{0, 1}, [2]int{3, 1}, [2]int{6, 1}, [2]int{9, 1}), 128, 2, []blockSpan{{0, 10}}},
+		{"max size", testImage([2]int{0, 4}, [2]int{4, 4}, [2]int{8, 4}), 8, 0, []blockSpan{{0, 8}, {8, 4}}},
+		{"gap beyond max size", testImage([2]int{0, 4}, [2]int{6, 4}), 8, 4, []blockSpan{{0, 4}, {6, 4}}},
+		{"large entry", testImage([2]int{0, 2}, [2]int{2, 300}, [2]int{302, 2}), 222, 0, []blockSpan{{0, 2}, {2, 300}, {302, 2}}},
+		{"pdu 240", testImage([2]int{0, 200}, [2]int{200, 22}, [2]int{222, 2}), Options{BlockSize: 512, PDUSize: 240}.blockLimit(), 0, []blockSpan{{0, 222}, {222, 2}}},
+		{"end of image", testImage([2]int{65532, 2}, [2]int{65534, 2}), 128, 4, []blockSpan{{65532, 4}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planImageBlocks(tt.image, tt.maxSize, tt.gap); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
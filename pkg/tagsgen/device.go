@@ -0,0 +1,131 @@
+package tagsgen
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+)
+
+// deviceModels - modele sterowników w sterowniku Siemens TCP/IP Ethernet (servermain.DEVICE_MODEL)
+// ================================================================================================
+var deviceModels = map[string]int{
+	"S7-200":         0,
+	"S7-300":         1,
+	"S7-400":         2,
+	"NetLink S7-300": 3,
+	"NetLink S7-400": 4,
+	"S7-1200":        5,
+	"S7-1500":        6,
+}
+
+// defaultSlots - typowy slot CPU dla modelu
+// ================================================================================================
+var defaultSlots = map[string]int{
+	"S7-300":         2,
+	"S7-400":         3,
+	"NetLink S7-300": 2,
+	"NetLink S7-400": 3,
+	"S7-1200":        1,
+	"S7-1500":        1,
+}
+
+// tsapPattern - TSAP jako 4 cyfry szesnastkowe, np. 4D57
+// ================================================================================================
+var tsapPattern = regexp.MustCompile(`^[0-9A-Fa-f]{4}$`)
+
+// DeviceOptions - parametry kanału i urządzenia Siemens TCP/IP Ethernet; pusty IP - bez definicji
+// ================================================================================================
+type DeviceOptions struct {
+	Model string
+	IP    string
+	Port  int
+
+	// Rack, Slot - położenie CPU (S7-300/400/1200/1500); Slot < 0 - typowy slot modelu
+	Rack int
+	Slot int
+
+	// LocalTSAP, RemoteTSAP - TSAP połączenia (S7-200), np. 4D57 i 4D57
+	LocalTSAP  string
+	RemoteTSAP string
+}
+
+// validate - sprawdzenie parametrów urządzenia
+// ================================================================================================
+func (d DeviceOptions) validate(connectionName string) error {
+	if d.IP == "" {
+		return nil
+	}
+	if _, _, err := SplitConnectionName(connectionName); err != nil {
+		return err
+	}
+	if _, ok := deviceModels[d.Model]; !ok {
+		return fmt.Errorf("unknown device model: %s", d.Model)
+	}
+	if net.ParseIP(d.IP) == nil {
+		return fmt.Errorf("invalid device IP address: %s", d.IP)
+	}
+	if d.Port <= 0 || d.Port > 65535 {
+		return fmt.Errorf("invalid device port: %d", d.Port)
+	}
+	if d.Rack < 0 || d.Rack > 7 || d.Slot > 31 {
+		return fmt.Errorf("invalid device rack/slot: %d/%d", d.Rack, d.Slot)
+	}
+	for _, tsap := range []string{d.LocalTSAP, d.RemoteTSAP} {
+		if tsap != "" && !tsapPattern.MatchString(tsap) {
+			return fmt.Errorf("invalid TSAP %q, expected 4 hex digits", tsap)
+		}
+	}
+	return nil
+}
+
+// generateChannel - definicja kanału z urządzeniem (POST do /config/v1/project/channels)
+// ================================================================================================
+func generateChannel(opts Options) KepObject {
+
+	channelName, deviceName, _ := SplitConnectionName(opts.ConnectionName)
+	d := opts.Device
+
+	slot := d.Slot
+	if slot < 0 {
+		slot = defaultSlots[d.Model]
+	}
+
+	device := KepObject{
+		"common.ALLTYPES_NAME":                      deviceName,
+		"common.ALLTYPES_DESCRIPTION":               fmt.Sprintf("%s %s, generated by tagsgenerator", d.Model, d.IP),
+		"servermain.MULTIPLE_TYPES_DEVICE_DRIVER":   SiemensDriver,
+		"servermain.DEVICE_MODEL":                   deviceModels[d.Model],
+		"servermain.DEVICE_ID_STRING":               "<" + d.IP + ">",
+		"siemens_tcpip_ethernet.DEVICE_PORT_NUMBER": d.Port,
+	}
+
+	if d.Model == "S7-200" {
+		device["siemens_tcpip_ethernet.DEVICE_S7_200_LOCAL_TSAP"] = d.LocalTSAP
+		device["siemens_tcpip_ethernet.DEVICE_S7_200_REMOTE_TSAP"] = d.RemoteTSAP
+	} else {
+		device["siemens_tcpip_ethernet.DEVICE_S7_300_400_1200_1500_CPU_RACK"] = d.Rack
+		device["siemens_tcpip_ethernet.DEVICE_S7_300_400_1200_1500_CPU_SLOT"] = slot
+		if d.LocalTSAP != "" || d.RemoteTSAP != "" {
+			device["siemens_tcpip_ethernet.DEVICE_S7_300_400_1200_1500_LOCAL_TSAP"] = d.LocalTSAP
+			device["siemens_tcpip_ethernet.DEVICE_S7_300_400_1200_1500_REMOTE_TSAP"] = d.RemoteTSAP
+		}
+	}
+
+	// bloki są planowane dla PDU - sterownik Kepware nie negocjuje większego
+	if opts.PDUSize > 0 {
+		device["siemens_tcpip_ethernet.DEVICE_S7_MAX_PDU_SIZE"] = opts.PDUSize
+	}
+
+	return KepObject{
+		"common.ALLTYPES_NAME":                    channelName,
+		"common.ALLTYPES_DESCRIPTION":             "Generated by tagsgenerator",
+		"servermain.MULTIPLE_TYPES_DEVICE_DRIVER": SiemensDriver,
+		"devices": []KepObject{device},
+	}
+}
+
+// DeviceModels - nazwy obsługiwanych modeli sterowników
+// ================================================================================================
+func DeviceModels() []string {
+	return []string{"S7-200", "S7-300", "S7-400", "S7-1200", "S7-1500", "NetLink S7-300", "NetLink S7-400"}
+}
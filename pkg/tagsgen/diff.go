@@ -0,0 +1,186 @@
+package tagsgen
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SymbolChange - symbol o zmienionym adresie w PLC lub przeniesiony do innego miejsca w tagach
+// ================================================================================================
+type SymbolChange struct {
+	SymbolName string
+	Old        CsvTag
+	New        CsvTag
+}
+
+// AlarmTextChange - alarm o zmienionych tekstach (Analog - alarm analogowy o tym numerze)
+// ================================================================================================
+type AlarmTextChange struct {
+	Number   int
+	Analog   bool `json:",omitempty"`
+	OldTexts LangTexts
+	NewTexts LangTexts
+}
+
+// BlockRename - blok tagów, którego wszystkie symbole trafiły do bloku o innej nazwie
+// ================================================================================================
+type BlockRename struct {
+	OldName string
+	NewName string
+}
+
+// Diff - różnice pomiędzy dwoma wygenerowanymi projektami (tags.json + alarms.json)
+// ================================================================================================
+type Diff struct {
+	AddedSymbols     []CsvTag
+	RemovedSymbols   []CsvTag
+	ChangedAddresses []SymbolChange
+	MovedSymbols     []SymbolChange
+	RenamedBlocks    []BlockRename
+
+	AddedAlarms       []CsvAlarm
+	RemovedAlarms     []CsvAlarm
+	ChangedAlarmTexts []AlarmTextChange
+}
+
+// Empty - czy projekty są takie same
+// ================================================================================================
+func (d *Diff) Empty() bool {
+	return len(d.AddedSymbols) == 0 && len(d.RemovedSymbols) == 0 &&
+		len(d.ChangedAddresses) == 0 && len(d.MovedSymbols) == 0 && len(d.RenamedBlocks) == 0 &&
+		len(d.AddedAlarms) == 0 && len(d.RemovedAlarms) == 0 && len(d.ChangedAlarmTexts) == 0
+}
+
+// SymbolAddress - adres symbolu w PLC w postaci "DB18 56.0", "I 10.6", "MW 90"
+// ================================================================================================
+func SymbolAddress(t CsvTag) string {
+	if t.SymbolAddressLo != "" {
+		return fmt.Sprintf("%s %s.%s", t.SymbolPeriph, t.SymbolAddressHi, t.SymbolAddressLo)
+	}
+	return fmt.Sprintf("%s %s", t.SymbolPeriph, t.SymbolAddressHi)
+}
+
+// tagsBySymbol - tagi wg nazwy symbolu (pierwsze wystąpienie) i nazwy w kolejności pliku
+// ================================================================================================
+func tagsBySymbol(tags Tags) (map[string]CsvTag, []string) {
+	m := make(map[string]CsvTag)
+	var names []string
+	for _, t := range tags.Tags {
+		if _, ok := m[t.SymbolName]; !ok {
+			m[t.SymbolName] = t
+			names = append(names, t.SymbolName)
+		}
+	}
+	return m, names
+}
+
+// alarmKey - numer i rodzaj alarmu; alarm dyskretny i analogowy mogą mieć ten sam numer
+// ================================================================================================
+type alarmKey struct {
+	number int
+	analog bool
+}
+
+func keyOfAlarm(a CsvAlarm) alarmKey {
+	return alarmKey{a.Number, a.Analog != nil}
+}
+
+// less - kolejność wg numeru, alarm dyskretny przed analogowym o tym samym numerze
+// ================================================================================================
+func (k alarmKey) less(other alarmKey) bool {
+	if k.number != other.number {
+		return k.number < other.number
+	}
+	return !k.analog && other.analog
+}
+
+// Compare - porównanie tagów i alarmów dwóch wygenerowanych projektów
+// ================================================================================================
+func Compare(oldTags Tags, oldAlarms Alarms, newTags Tags, newAlarms Alarms) *Diff {
+
+	var d Diff
+
+	// symbole
+	// ----------------------------------------------
+	oldSyms, oldNames := tagsBySymbol(oldTags)
+	newSyms, newNames := tagsBySymbol(newTags)
+
+	// blok poprzedniej generacji -> bloki, do których trafiły jego symbole
+	blockTargets := make(map[string]map[string]bool)
+	var oldBlocks []string
+
+	for _, name := range oldNames {
+		o := oldSyms[name]
+		n, ok := newSyms[name]
+		if !ok {
+			d.RemovedSymbols = append(d.RemovedSymbols, o)
+			continue
+		}
+
+		if o.DataType == kepByteArray || o.DataType == "" {
+			if _, ok := blockTargets[o.TagName]; !ok {
+				blockTargets[o.TagName] = make(map[string]bool)
+				oldBlocks = append(oldBlocks, o.TagName)
+			}
+			blockTargets[o.TagName][n.TagName] = true
+		}
+
+		if SymbolAddress(o) != SymbolAddress(n) {
+			d.ChangedAddresses = append(d.ChangedAddresses, SymbolChange{SymbolName: name, Old: o, New: n})
+		} else if o.TagName != n.TagName || o.Index != n.Index || o.BitNr != n.BitNr {
+			d.MovedSymbols = append(d.MovedSymbols, SymbolChange{SymbolName: name, Old: o, New: n})
+		}
+	}
+
+	for _, name := range newNames {
+		if _, ok := oldSyms[name]; !ok {
+			d.AddedSymbols = append(d.AddedSymbols, newSyms[name])
+		}
+	}
+
+	// zmiana nazwy bloku - wszystkie pozostałe symbole bloku w jednym bloku o innej nazwie
+	for _, block := range oldBlocks {
+		if len(blockTargets[block]) != 1 {
+			continue
+		}
+		for target := range blockTargets[block] {
+			if target != block {
+				d.RenamedBlocks = append(d.RenamedBlocks, BlockRename{OldName: block, NewName: target})
+			}
+		}
+	}
+
+	// alarmy
+	// ----------------------------------------------
+	oldByKey := make(map[alarmKey]CsvAlarm)
+	for _, a := range oldAlarms.Alarms {
+		oldByKey[keyOfAlarm(a)] = a
+	}
+	newByKey := make(map[alarmKey]CsvAlarm)
+	for _, a := range newAlarms.Alarms {
+		newByKey[keyOfAlarm(a)] = a
+	}
+
+	for _, o := range oldAlarms.Alarms {
+		n, ok := newByKey[keyOfAlarm(o)]
+		if !ok {
+			d.RemovedAlarms = append(d.RemovedAlarms, o)
+		} else if !o.Texts.Equal(n.Texts) {
+			d.ChangedAlarmTexts = append(d.ChangedAlarmTexts, AlarmTextChange{Number: o.Number, Analog: o.Analog != nil, OldTexts: o.Texts, NewTexts: n.Texts})
+		}
+	}
+	for _, n := range newAlarms.Alarms {
+		if _, ok := oldByKey[keyOfAlarm(n)]; !ok {
+			d.AddedAlarms = append(d.AddedAlarms, n)
+		}
+	}
+
+	sort.Slice(d.AddedAlarms, func(i, j int) bool { return keyOfAlarm(d.AddedAlarms[i]).less(keyOfAlarm(d.AddedAlarms[j])) })
+	sort.Slice(d.RemovedAlarms, func(i, j int) bool { return keyOfAlarm(d.RemovedAlarms[i]).less(keyOfAlarm(d.RemovedAlarms[j])) })
+	sort.Slice(d.ChangedAlarmTexts, func(i, j int) bool {
+		a, b := d.ChangedAlarmTexts[i], d.ChangedAlarmTexts[j]
+		return alarmKey{a.Number, a.Analog}.less(alarmKey{b.Number, b.Analog})
+	})
+
+	return &d
+}
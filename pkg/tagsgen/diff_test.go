@@ -0,0 +1,87 @@
+package tagsgen
+
+import "testing"
+
+// alarm - alarm bitowy lub analogowy z tekstem de-DE
+// ================================================================================================
+func alarm(number int, analog bool, text string) CsvAlarm {
+	a := CsvAlarm{Number: number, Texts: LangTexts{"de-DE": text}}
+	if analog {
+		a.BitNr = -1
+		a.Analog = &AnalogAlarm{Mode: AnalogAbove}
+	}
+	return a
+}
+
+func TestCompareAlarmsSameNumber(t *testing.T) {
+
+	// alarm dyskretny 12 i analogowy A12 nie są tym samym alarmem
+	oldAlarms := Alarms{Alarms: []CsvAlarm{alarm(12, false, "Not-Aus"), alarm(12, true, "Temperatur")}}
+	newAlarms := Alarms{Alarms: []CsvAlarm{alarm(12, true, "Temperatur hoch"), alarm(13, false, "Tür offen")}}
+
+	d := Compare(Tags{}, oldAlarms, Tags{}, newAlarms)
+
+	if len(d.RemovedAlarms) != 1 || d.RemovedAlarms[0].Number != 12 || d.RemovedAlarms[0].Analog != nil {
+		t.Errorf("removed = %+v", d.RemovedAlarms)
+	}
+	if len(d.AddedAlarms) != 1 || d.AddedAlarms[0].Number != 13 {
+		t.Errorf("added = %+v", d.AddedAlarms)
+	}
+	if len(d.ChangedAlarmTexts) != 1 || !d.ChangedAlarmTexts[0].Analog || d.ChangedAlarmTexts[0].NewTexts["de-DE"] != "Temperatur hoch" {
+		t.Errorf("changed = %+v", d.ChangedAlarmTexts)
+	}
+
+	// bez zmian - żadnych fałszywych różnic przy wspólnym numerze
+	if d := Compare(Tags{}, oldAlarms, Tags{}, oldAlarms); !d.Empty() {
+		t.Errorf("diff of same alarms = %+v", d)
+	}
+
+	// sortowanie: dyskretny przed analogowym
+	d = Compare(Tags{}, Alarms{}, Tags{}, Alarms{Alarms: []CsvAlarm{alarm(12, true, "A"), alarm(12, false, "B"), alarm(3, true, "C")}})
+	if a := d.AddedAlarms; len(a) != 3 || a[0].Number != 3 || a[1].Analog != nil || a[2].Analog == nil {
+		t.Errorf("added order = %+v", a)
+	}
+}
+
+func TestCompareSymbols(t *testing.T) {
+
+	tag := func(name, periph, addr, tagName string, index int) CsvTag {
+		return CsvTag{SymbolName: name, SymbolPeriph: periph, SymbolAddressHi: addr, TagName: tagName, DataType: kepByteArray, Index: index}
+	}
+	oldTags := Tags{Tags: []CsvTag{
+		tag("Speed", "MW", "10", "tabMB_10", 0),
+		tag("Level", "MW", "12", "tabMB_10", 2),
+		tag("Removed", "MW", "20", "tabMB_20", 0),
+		tag("Moved", "DB5", "0", "tabDB5_0", 0),
+	}}
+	newTags := Tags{Tags: []CsvTag{
+		tag("Speed", "MW", "14", "tabMB_8", 6),
+		tag("Level", "MW", "12", "tabMB_8", 4),
+		tag("Moved", "DB5", "0", "tabDB5_0", 2),
+		tag("Added", "MW", "30", "tabMB_30", 0),
+	}}
+
+	d := Compare(oldTags, Alarms{}, newTags, Alarms{})
+
+	if len(d.AddedSymbols) != 1 || d.AddedSymbols[0].SymbolName != "Added" {
+		t.Errorf("added = %+v", d.AddedSymbols)
+	}
+	if len(d.RemovedSymbols) != 1 || d.RemovedSymbols[0].SymbolName != "Removed" {
+		t.Errorf("removed = %+v", d.RemovedSymbols)
+	}
+	if len(d.ChangedAddresses) != 1 || d.ChangedAddresses[0].SymbolName != "Speed" {
+		t.Errorf("changed addresses = %+v", d.ChangedAddresses)
+	}
+	if len(d.MovedSymbols) != 2 || d.MovedSymbols[0].SymbolName != "Level" || d.MovedSymbols[1].SymbolName != "Moved" {
+		t.Errorf("moved = %+v", d.MovedSymbols)
+	}
+
+	// wszystkie symbole tabMB_10 w tabMB_8; tabDB5_0 bez zmiany nazwy
+	if r := d.RenamedBlocks; len(r) != 1 || r[0] != (BlockRename{OldName: "tabMB_10", NewName: "tabMB_8"}) {
+		t.Errorf("renamed blocks = %+v", r)
+	}
+
+	if d := Compare(oldTags, Alarms{}, oldTags, Alarms{}); !d.Empty() {
+		t.Errorf("diff of same tags = %+v", d)
+	}
+}
@@ -0,0 +1,120 @@
+package tagsgen
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rodzaje błędów wczytywania plików wejściowych
+// ================================================================================================
+var (
+	ErrMalformedLine      = errors.New("malformed line")
+	ErrInvalidAddress     = errors.New("invalid address")
+	ErrUnsupportedAddress = errors.New("unsupported address")
+	ErrNoAbsoluteAddress  = errors.New("no absolute address")
+	ErrInvalidNumber      = errors.New("invalid alarm number")
+	ErrInvalidBitNumber   = errors.New("invalid bit number")
+)
+
+// Przyczyny pominięcia alarmu, którego taga nie da się wskazać w tagach Kepware
+// ================================================================================================
+var (
+	ErrUnknownTag      = errors.New("unknown tag")
+	ErrNotInBlock      = errors.New("address not in block")
+	ErrBitOutsideBlock = errors.New("bit outside block")
+	ErrDuplicateNumber = errors.New("duplicate alarm number")
+	ErrUnresolvedAck   = errors.New("unresolved ack tag")
+)
+
+// UnresolvedAlarm - alarm pominięty w alarms.json, bo jego taga nie da się wskazać w tagach
+// Kepware (BitNr -1 dla alarmów analogowych); Reason to tekst jednej z przyczyn powyżej.
+// Przy ErrUnresolvedAck alarm jest zapisany bez wskaźnika potwierdzenia, a TriggerTag i BitNr
+// są tagiem i bitem potwierdzenia
+// ================================================================================================
+type UnresolvedAlarm struct {
+	Number     int
+	TriggerTag string
+	BitNr      int
+	Reason     string
+	Details    string
+}
+
+// unresolvedReason - przyczyna pominięcia alarmu z błędu wskazania taga
+// ================================================================================================
+func unresolvedReason(err error) string {
+	for _, reason := range []error{ErrUnresolvedAck, ErrUnknownTag, ErrNotInBlock, ErrBitOutsideBlock, ErrDuplicateNumber} {
+		if errors.Is(err, reason) {
+			return reason.Error()
+		}
+	}
+	return err.Error()
+}
+
+// ParseError - błąd wczytywania jednej linii (wiersza) pliku wejściowego; linia jest pomijana
+// ================================================================================================
+type ParseError struct {
+	File string
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	if e.Text != "" {
+		return fmt.Sprintf("%s:%d: %v: %q", e.File, e.Line, e.Err, e.Text)
+	}
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// parseErrorf - dodanie błędu wczytywania linii do listy błędów projektu
+// ================================================================================================
+func (p *Project) parseErrorf(file string, line int, text string, err error, format string, a ...interface{}) {
+	if format != "" {
+		err = fmt.Errorf("%w: "+format, append([]interface{}{err}, a...)...)
+	}
+	p.ParseErrors = append(p.ParseErrors, &ParseError{File: file, Line: line, Text: text, Err: err})
+}
+
+// parseBitNr - numer bitu z kolumny alarmu (bit wyzwalający, bity potwierdzenia); pusta kolumna
+// to bit 0, chyba że numer jest wymagany
+// ================================================================================================
+func parseBitNr(s string, required bool) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" && !required {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, ErrInvalidBitNumber
+	}
+	return n, nil
+}
+
+// checkAddress - sprawdzenie adresu symbolu w obszarze I, M, Q lub DB
+// ================================================================================================
+func checkAddress(sym Symbol) error {
+
+	if !isAddressable(sym) {
+		return nil
+	}
+
+	hi, err := strconv.Atoi(sym.AddressHi)
+	if err != nil || hi < 0 || hi > 65535 {
+		return fmt.Errorf("%w: byte %q", ErrInvalidAddress, sym.AddressHi)
+	}
+
+	if sym.AddressLo != "" {
+		lo, err := strconv.Atoi(sym.AddressLo)
+		if err != nil || lo < 0 || lo > 7 {
+			return fmt.Errorf("%w: bit %q", ErrInvalidAddress, sym.AddressLo)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package tagsgen
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// globPattern - wyrażenie regularne dla wzorca nazwy z * i ? (bez rozróżniania wielkości liter)
+// ================================================================================================
+func globPattern(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// globPatterns - lista wzorców nazw
+// ================================================================================================
+func globPatterns(globs []string) ([]*regexp.Regexp, error) {
+	var list []*regexp.Regexp
+	for _, g := range globs {
+		re, err := globPattern(g)
+		if err != nil {
+			return nil, fmt.Errorf("invalid symbol filter %q: %w", g, err)
+		}
+		list = append(list, re)
+	}
+	return list, nil
+}
+
+// matchAny - czy nazwa pasuje do któregoś wzorca
+// ================================================================================================
+func matchAny(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterSymbols - pozostawienie symboli, których nazwa pasuje do któregoś wzorca include (pusta
+// lista - wszystkie) i nie pasuje do żadnego wzorca exclude; wzorce z * i ?, np. "AMD.*"
+// Zwraca liczbę usuniętych symboli.
+// ================================================================================================
+func (p *Project) FilterSymbols(include []string, exclude []string) (int, error) {
+
+	inc, err := globPatterns(include)
+	if err != nil {
+		return 0, err
+	}
+	exc, err := globPatterns(exclude)
+	if err != nil {
+		return 0, err
+	}
+
+	var kept []Symbol
+	for _, sym := range p.Symbols {
+		name := strings.ReplaceAll(sym.Name, "\"", "")
+		if (len(inc) == 0 || matchAny(inc, name)) && !matchAny(exc, name) {
+			kept = append(kept, sym)
+		}
+	}
+
+	removed := len(p.Symbols) - len(kept)
+	p.Symbols = kept
+	return removed, nil
+}
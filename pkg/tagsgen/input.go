@@ -0,0 +1,130 @@
+package tagsgen
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// decodeWindows1250 - dekodowanie ASCII
+// ================================================================================================
+func decodeWindows1250(enc string) string {
+	dec := charmap.Windows1250.NewDecoder()
+	out, _ := dec.String(enc)
+	return string(out)
+}
+
+// readLines reads a whole input into memory and returns a slice of its lines.
+// ================================================================================================
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// readUTF16 - Similar to ioutil.ReadAll() but decodes UTF-16.  Useful when
+// reading data from MS-Windows systems that generate UTF-16BE files,
+// but will do the right thing if other BOMs are found.
+// ================================================================================================
+func readUTF16(r io.Reader) ([]byte, error) {
+
+	// Make an tranformer that converts MS-Win default to UTF8:
+	win16be := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	// Make a transformer that is like win16be, but abides by BOM:
+	utf16bom := unicode.BOMOverride(win16be.NewDecoder())
+
+	// Make a Reader that uses utf16bom:
+	unicodeReader := transform.NewReader(r, utf16bom)
+
+	// decode:
+	return io.ReadAll(unicodeReader)
+}
+
+// readLinesUTF16 - reads a whole UTF-16 input into memory and returns a slice of its lines.
+// ================================================================================================
+func readLinesUTF16(r io.Reader) ([]string, error) {
+	decoded, err := readUTF16(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	scanner := bufio.NewReader(bytes.NewReader(decoded))
+	for {
+		line, _, err := scanner.ReadLine()
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, string(line))
+	}
+}
+
+// loadFile - otwarcie pliku i przekazanie go do funkcji wczytującej
+// ================================================================================================
+func loadFile(filename string, load func(r io.Reader) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return load(file)
+}
+
+// LoadSymbolsFile - wczytanie tablicy symboli Step7 (Symbols.asc) lub TIA Portal (PLCTags.sdf)
+// ================================================================================================
+func (p *Project) LoadSymbolsFile(filename string) error {
+	return loadFile(filename, func(r io.Reader) error {
+		if strings.Contains(filename, ".sdf") {
+			return p.loadPLCSymbols(r, filename, ".sdf")
+		}
+		return p.loadPLCSymbols(r, filename, ".asc")
+	})
+}
+
+// LoadHmiTagsFile - wczytanie tagów HMI WinCC flexible (Tags.csv) lub TIA Portal (HMITags.xlsx)
+// ================================================================================================
+func (p *Project) LoadHmiTagsFile(filename string) error {
+	return loadFile(filename, func(r io.Reader) error {
+		if isXlsx(filename) {
+			return p.loadTIAHmiTags(r, filename)
+		}
+		return p.loadFlexTags(r, filename)
+	})
+}
+
+// LoadAlarmsFile - wczytanie alarmów WinCC flexible (Alarms.csv) lub TIA Portal (HMIAlarms.xlsx)
+// ================================================================================================
+func (p *Project) LoadAlarmsFile(filename string) error {
+	if isXlsx(filename) {
+		return loadFile(filename, func(r io.Reader) error {
+			return p.LoadTIAAlarms(r, filename)
+		})
+	}
+	return loadFile(filename, func(r io.Reader) error {
+		return p.LoadFlexAlarms(r, filename)
+	})
+}
+
+// LoadBaselineFile - wczytanie bloków poprzedniej generacji z plc.csv lub tags.json
+// ================================================================================================
+func (p *Project) LoadBaselineFile(filename string) error {
+	return loadFile(filename, func(r io.Reader) error {
+		if strings.Contains(filename, ".json") {
+			return p.LoadBaselineTags(r)
+		}
+		return p.LoadBaselinePLC(r)
+	})
+}
@@ -0,0 +1,301 @@
+package tagsgen
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// KepObject - obiekt Kepware Configuration API: właściwości ("common.ALLTYPES_NAME", ...)
+// i listy obiektów podrzędnych ("iot_items", ...)
+// ================================================================================================
+type KepObject map[string]interface{}
+
+// Name - nazwa obiektu (common.ALLTYPES_NAME)
+// ================================================================================================
+func (o KepObject) Name() string {
+	name, _ := o["common.ALLTYPES_NAME"].(string)
+	return name
+}
+
+// kepDataTypes - kody typów danych Kepware (servermain.TAG_DATA_TYPE, iot_gateway.IOT_ITEM_DATA_TYPE)
+// ================================================================================================
+var kepDataTypes = map[string]int{
+	"Default": -1,
+	"String":  0,
+	"Boolean": 1,
+	"Char":    2,
+	"Byte":    3,
+	"Short":   4,
+	"Word":    5,
+	"Long":    6,
+	"DWord":   7,
+	"Float":   8,
+	"Double":  9,
+	"BCD":     10,
+	"LBCD":    11,
+	"Date":    12,
+	"LLong":   13,
+	"QWord":   14,
+}
+
+// kepDataType - kod typu danych Kepware; tablice mają kod typu elementu + 20
+// ================================================================================================
+func kepDataType(dataType string) int {
+	if elem := strings.TrimSuffix(dataType, " Array"); elem != dataType {
+		if code, ok := kepDataTypes[elem]; ok && code >= 0 {
+			return code + 20
+		}
+		return -1
+	}
+	if code, ok := kepDataTypes[dataType]; ok {
+		return code
+	}
+	return -1
+}
+
+// plcLineFields - pola linii taga z plc.csv (nazwa, adres, typ danych, ...); false dla linii
+// nagłówka i komentarzy
+// ================================================================================================
+func plcLineFields(line string) ([]string, bool) {
+
+	if !strings.Contains(line, "\"") || !strings.Contains(line, ",") {
+		return nil, false
+	}
+	r := csv.NewReader(strings.NewReader(line))
+	r.FieldsPerRecord = -1
+	fields, err := r.Read()
+	if err != nil || len(fields) < 3 {
+		return nil, false
+	}
+	return fields, true
+}
+
+// Rodzaje agentów IoT Gateway
+// ================================================================================================
+const (
+	AgentMQTT       = "mqtt"
+	AgentRESTClient = "rest_client"
+	AgentRESTServer = "rest_server"
+)
+
+// AgentCollections - kolekcje agentów w Configuration API: /config/v1/project/_iot_gateway/<kolekcja>
+// ================================================================================================
+var AgentCollections = map[string]string{
+	AgentMQTT:       "mqtt_clients",
+	AgentRESTClient: "rest_clients",
+	AgentRESTServer: "rest_servers",
+}
+
+// AgentOptions - parametry agenta IoT Gateway; pusty Type - bez agenta
+// ================================================================================================
+type AgentOptions struct {
+	Type string
+	Name string
+
+	// URL - adres brokera MQTT (tcp://host:1883) lub adres, pod który REST Client wysyła
+	// komunikaty (http://host:8080/iotgateway - polecenie serve)
+	URL      string
+	Topic    string
+	QoS      int
+	ClientID string
+	Username string
+	Password string
+
+	// Rate - okres publikacji [ms]; OnChange - publikacja tylko zmienionych wartości
+	Rate     int
+	OnChange bool
+
+	// Template - szablon zaawansowany komunikatu; pusty - szablon standardowy
+	Template string
+
+	// Port - port agenta REST Server
+	Port int
+}
+
+// defaultAgentURLs - domyślne adresy agentów
+// ================================================================================================
+var defaultAgentURLs = map[string]string{
+	AgentMQTT:       "tcp://localhost:1883",
+	AgentRESTClient: "http://localhost:8080/iotgateway",
+}
+
+// validate - sprawdzenie parametrów agenta
+// ================================================================================================
+func (a AgentOptions) validate() error {
+	if a.Type == "" {
+		return nil
+	}
+	if _, ok := AgentCollections[a.Type]; !ok {
+		return fmt.Errorf("unknown IoT Gateway agent type: %s", a.Type)
+	}
+	if a.Name == "" {
+		return fmt.Errorf("missing IoT Gateway agent name")
+	}
+	if a.Rate < 0 || a.QoS < 0 || a.QoS > 2 || a.Port < 0 || a.Port > 65535 {
+		return fmt.Errorf("invalid IoT Gateway agent parameters: rate %d, QoS %d, port %d", a.Rate, a.QoS, a.Port)
+	}
+	return nil
+}
+
+// iotItems - elementy IoT Gateway agenta dla tagów z plc.csv (jak w iot.csv)
+// ================================================================================================
+func iotItems(plc []string, connectionName string, freq int) (items []KepObject) {
+
+	for _, line := range plc {
+		fields, ok := plcLineFields(line)
+		if !ok {
+			continue
+		}
+
+		serverTag := connectionName + "." + fields[0]
+		items = append(items, KepObject{
+			"common.ALLTYPES_NAME":                  strings.ReplaceAll(serverTag, ".", "_"),
+			"iot_gateway.IOT_ITEM_SERVER_TAG":       serverTag,
+			"iot_gateway.IOT_ITEM_USE_SCAN_RATE":    true,
+			"iot_gateway.IOT_ITEM_SCAN_RATE_MS":     freq,
+			"iot_gateway.IOT_ITEM_SEND_EVERY_SCAN":  false,
+			"iot_gateway.IOT_ITEM_DEADBAND_PERCENT": 0,
+			"iot_gateway.IOT_ITEM_ENABLED":          true,
+			"iot_gateway.IOT_ITEM_DATA_TYPE":        kepDataType(fields[2]),
+		})
+	}
+	return
+}
+
+// generateAgent - definicja agenta IoT Gateway z elementami (POST do
+// /config/v1/project/_iot_gateway/<kolekcja agenta>)
+// ================================================================================================
+func generateAgent(plc []string, opts Options) KepObject {
+
+	a := opts.Agent
+	agent := KepObject{
+		"common.ALLTYPES_NAME":           a.Name,
+		"common.ALLTYPES_DESCRIPTION":    "Generated by tagsgenerator for " + opts.ConnectionName,
+		"iot_gateway.AGENTTYPES_ENABLED": true,
+	}
+
+	url := a.URL
+	if url == "" {
+		url = defaultAgentURLs[a.Type]
+	}
+
+	switch a.Type {
+	case AgentMQTT:
+		agent["iot_gateway.MQTT_CLIENT_URL"] = url
+		agent["iot_gateway.MQTT_CLIENT_TOPIC"] = a.Topic
+		agent["iot_gateway.MQTT_CLIENT_QOS"] = a.QoS
+		agent["iot_gateway.MQTT_CLIENT_CLIENT_ID"] = a.ClientID
+		agent["iot_gateway.MQTT_CLIENT_USERNAME"] = a.Username
+		agent["iot_gateway.MQTT_CLIENT_PASSWORD"] = a.Password
+	case AgentRESTClient:
+		agent["iot_gateway.REST_CLIENT_URL"] = url
+		agent["iot_gateway.REST_CLIENT_METHOD"] = 0 // POST
+	case AgentRESTServer:
+		agent["iot_gateway.REST_SERVER_PORT_NUMBER"] = a.Port
+		agent["iot_gateway.REST_SERVER_ENABLE_WRITE_ENDPOINT"] = false
+	}
+
+	// agenci publikujący: okres, tryb i format komunikatu
+	if a.Type != AgentRESTServer {
+		publishFormat := 0 // co okres
+		if a.OnChange {
+			publishFormat = 1 // tylko zmiany
+		}
+		agent["iot_gateway.AGENTTYPES_RATE_MS"] = a.Rate
+		agent["iot_gateway.AGENTTYPES_PUBLISH_FORMAT"] = publishFormat
+		agent["iot_gateway.AGENTTYPES_MAX_EVENTS"] = 1000
+		agent["iot_gateway.AGENTTYPES_TIMEOUT_S"] = 5
+		if a.Template != "" {
+			agent["iot_gateway.AGENTTYPES_MESSAGE_FORMAT"] = 1 // szablon zaawansowany
+			agent["iot_gateway.AGENTTYPES_ADVANCED_TEMPLATE"] = a.Template
+		} else {
+			agent["iot_gateway.AGENTTYPES_MESSAGE_FORMAT"] = 0 // szablon standardowy
+		}
+	}
+
+	agent["iot_items"] = iotItems(plc, opts.ConnectionName, opts.ScanRate)
+	return agent
+}
+
+// SiemensDriver - sterownik kanału Kepware dla sterowników S7
+// ================================================================================================
+const SiemensDriver = "Siemens TCP/IP Ethernet"
+
+// SplitConnectionName - kanał i urządzenie z nazwy połączenia ("SiemensTCPIP.PLC")
+// ================================================================================================
+func SplitConnectionName(connectionName string) (channel string, device string, err error) {
+	i := strings.Index(connectionName, ".")
+	if i <= 0 || i == len(connectionName)-1 || strings.Count(connectionName, ".") != 1 {
+		return "", "", fmt.Errorf("invalid connection name %q, expected <channel>.<device>", connectionName)
+	}
+	return connectionName[:i], connectionName[i+1:], nil
+}
+
+// ConfigTags - tagi urządzenia w formacie Configuration API z linii plc.csv
+// (POST do /config/v1/project/channels/<kanał>/devices/<urządzenie>/tags)
+// ================================================================================================
+func ConfigTags(plc []string) (tags []KepObject) {
+
+	for _, line := range plc {
+		fields, ok := plcLineFields(line)
+		if !ok || len(fields) < 6 {
+			continue
+		}
+
+		access := 0 // RO
+		if fields[4] == "RW" || fields[4] == "R/W" {
+			access = 1
+		}
+		scanRate, _ := strconv.Atoi(fields[5])
+		description := ""
+		if len(fields) > 15 {
+			description = fields[15]
+		}
+
+		tags = append(tags, KepObject{
+			"common.ALLTYPES_NAME":                  fields[0],
+			"common.ALLTYPES_DESCRIPTION":           description,
+			"servermain.TAG_ADDRESS":                fields[1],
+			"servermain.TAG_DATA_TYPE":              kepDataType(fields[2]),
+			"servermain.TAG_READ_WRITE_ACCESS":      access,
+			"servermain.TAG_SCAN_RATE_MILLISECONDS": scanRate,
+		})
+	}
+	return
+}
+
+// AgentType - rodzaj agenta IoT Gateway na podstawie jego właściwości
+// ================================================================================================
+func AgentType(agent KepObject) string {
+	for key := range agent {
+		switch {
+		case strings.HasPrefix(key, "iot_gateway.MQTT_CLIENT_"):
+			return AgentMQTT
+		case strings.HasPrefix(key, "iot_gateway.REST_CLIENT_"):
+			return AgentRESTClient
+		case strings.HasPrefix(key, "iot_gateway.REST_SERVER_"):
+			return AgentRESTServer
+		}
+	}
+	return ""
+}
+
+// LoadConfigTagsFile - tagi w formacie Configuration API z pliku plc.csv
+// ================================================================================================
+func LoadConfigTagsFile(filename string) ([]KepObject, error) {
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines, err := readLines(file)
+	if err != nil {
+		return nil, err
+	}
+	return ConfigTags(lines), nil
+}
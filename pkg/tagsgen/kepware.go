@@ -0,0 +1,217 @@
+package tagsgen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// s7Type - typ danych S7 i odpowiadający mu typ danych oraz adresu w sterowniku Siemens TCP/IP Kepware
+// ================================================================================================
+type s7Type struct {
+	size    int
+	kepType string
+	kepAddr string
+}
+
+var s7Types = map[string]s7Type{
+	"BOOL":          {0, "Boolean", "X"},
+	"BYTE":          {1, "Byte", "BYTE"},
+	"USINT":         {1, "Byte", "BYTE"},
+	"CHAR":          {1, "Char", "CHAR"},
+	"SINT":          {1, "Char", "CHAR"},
+	"WORD":          {2, "Word", "WORD"},
+	"UINT":          {2, "Word", "WORD"},
+	"INT":           {2, "Short", "INT"},
+	"DWORD":         {4, "DWord", "DWORD"},
+	"UDINT":         {4, "DWord", "DWORD"},
+	"DINT":          {4, "Long", "DINT"},
+	"TIME":          {4, "Long", "TIME"},
+	"REAL":          {4, "Float", "REAL"},
+	"STRING":        {0, "String", "STRING"},
+	"DATE AND TIME": {8, "Date", "DT"},
+	"DATE_AND_TIME": {8, "Date", "DT"},
+}
+
+// s7SizeTypes - typ danych na podstawie rozmiaru adresu, gdy typ symbolu jest nieznany
+// ================================================================================================
+var s7SizeTypes = map[int]string{0: "BOOL", 1: "BYTE", 2: "WORD", 4: "DWORD"}
+
+// arraySize - rozmiar w bajtach stringu (z nagłówkiem S7) lub tablicy elementów o rozmiarze sSize
+// ================================================================================================
+func arraySize(sSize string, dataType string, length int, count int) string {
+	elemSize, _ := strconv.Atoi(sSize)
+
+	if strings.EqualFold(dataType, "String") && length > 0 {
+		return strconv.Itoa(length + 2)
+	}
+	if count > 1 && elemSize > 0 {
+		return strconv.Itoa(count * elemSize)
+	}
+	return sSize
+}
+
+// symbolTagName - nazwa taga Kepware dla pojedynczego symbolu
+// ================================================================================================
+func symbolTagName(sym Symbol) string {
+	return strings.ReplaceAll(sym.Name, "\"", "")
+}
+
+// symbolS7Type - typ danych symbolu; rozmiar adresu, gdy typ jest nieznany
+// ================================================================================================
+func symbolS7Type(sym Symbol) (s7Type, bool) {
+	if t, found := s7Types[strings.ToUpper(sym.DataType)]; found {
+		return t, true
+	}
+	size, _ := strconv.Atoi(sym.Size)
+	t, found := s7Types[s7SizeTypes[size]]
+	return t, found
+}
+
+// isStandalone - czy symbol w trybie hybrydowym dostaje własny tag z typem danych
+// (słowa, podwójne słowa, liczby rzeczywiste, stringi i ich tablice)
+// ================================================================================================
+func isStandalone(sym Symbol) bool {
+	if !isAddressable(sym) {
+		return false
+	}
+	t, found := symbolS7Type(sym)
+	return found && (t.size >= 2 || t.kepAddr == "STRING")
+}
+
+// isAddressable - czy symbol ma adres w obszarze I, M, Q lub DB
+// ================================================================================================
+func isAddressable(sym Symbol) bool {
+	if sym.Type == "FB" || sym.Type == "DB" || sym.Type == "FC" || sym.Type == "TIMER" || sym.Type == "UDT" {
+		return false
+	}
+	if len(sym.AddressHi) == 0 {
+		return false
+	}
+	switch blockType(sym.Periph) {
+	case "IB", "MB", "QB":
+		return true
+	}
+	return strings.HasPrefix(sym.Periph, "DB") && len(sym.Periph) > 2
+}
+
+// prepPLCSymbolTag - tag Kepware z właściwym typem danych dla pojedynczego symbolu
+// "AMD.MeldungFr1","DB18,INT72",Short,1,RO,100,,,,,,,,,,"Meldungen Fronius 1 R4",
+// "MxAbAct_Sl","MDINT90",Long,1,RO,100,,,,,,,,,,"DP: Abwahl Slave aktiv",
+// ================================================================================================
+func prepPLCSymbolTag(sym Symbol, freq int) (line string, newTag KepTag, ok bool) {
+
+	if !isAddressable(sym) {
+		return
+	}
+
+	hiAddress, err := strconv.Atoi(sym.AddressHi)
+	if err != nil {
+		return
+	}
+	size, _ := strconv.Atoi(sym.Size)
+
+	t, found := symbolS7Type(sym)
+	if !found {
+		return
+	}
+
+	var area string
+	if strings.HasPrefix(sym.Periph, "DB") {
+		area = sym.Periph + ","
+	} else {
+		area = sym.Periph[:1]
+	}
+
+	dataType := t.kepType
+	var address string
+
+	switch {
+	case t.kepAddr == "X":
+		loAddress := sym.AddressLo
+		if loAddress == "" {
+			loAddress = "0"
+		}
+		address = fmt.Sprintf("%sX%d.%s", area, hiAddress, loAddress)
+	case t.kepAddr == "STRING":
+		if size < 3 {
+			return
+		}
+		address = fmt.Sprintf("%sSTRING%d.%d", area, hiAddress, size-2)
+	case t.size > 0 && size > t.size:
+		address = fmt.Sprintf("%s%s%d[%d]", area, t.kepAddr, hiAddress, size/t.size)
+		dataType += " Array"
+	default:
+		address = fmt.Sprintf("%s%s%d", area, t.kepAddr, hiAddress)
+		if size < t.size {
+			size = t.size
+		}
+	}
+
+	newTag.Name = symbolTagName(sym)
+	newTag.Type = blockType(sym.Periph)
+	newTag.DataType = dataType
+	newTag.StartingIndex = hiAddress
+	newTag.Size = size
+	if newTag.Size == 0 {
+		newTag.Size = 1
+	}
+
+	comment := strings.ReplaceAll(sym.Comment, "\"", "\"\"")
+	line = fmt.Sprintf("\"%s\",\"%s\",%s,1,RO,%d,,,,,,,,,,\"%s\",", newTag.Name, address, dataType, freq, comment)
+	ok = true
+	return
+}
+
+// prepPLCSymbolTags - wygenerowanie listy tagów - jeden tag Kepware na każdy symbol
+// (standaloneOnly - tylko symbole analogowe dla trybu hybrydowego); symbol o nazwie taga, który
+// już istnieje, jest pomijany i zapisywany w DuplicateSymbols
+// ================================================================================================
+func (p *Project) prepPLCSymbolTags(freq int, standaloneOnly bool) (outLines []string) {
+
+	names := make(map[string]bool)
+
+	for _, sym := range p.Symbols {
+
+		if standaloneOnly && !isStandalone(sym) {
+			continue
+		}
+
+		line, newTag, ok := prepPLCSymbolTag(sym, freq)
+		if !ok {
+			continue
+		}
+		if names[newTag.Name] {
+			p.DuplicateSymbols = append(p.DuplicateSymbols, sym)
+			continue
+		}
+		names[newTag.Name] = true
+
+		p.KepTags = append(p.KepTags, newTag)
+		outLines = append(outLines, line)
+	}
+
+	return
+}
+
+// generateIOT - funkcja generująca plik iot
+// "tabIB0","IB0[8]",Byte Array,1,R,100,,,,,,,,,,"",
+// "SiemensTCPIP.LivePLC01.tabIB0",100,Byte Array,0.000000,0,0,1
+// ================================================================================================
+func generateIOT(plc []string, connectionName string, freq int) (iot []string) {
+	iot = append(iot, ";")
+	iot = append(iot, "; IOTItem")
+	iot = append(iot, ";")
+	iot = append(iot, "Server Tag,Scan Rate,Data Type,Deadband,Send Every Scan,Enabled,Use Scan Rate,")
+	for _, line := range plc {
+
+		if fields, ok := plcLineFields(line); ok {
+			tagName := fields[0]
+			dataType := fields[2]
+
+			outLine := fmt.Sprintf("\"%s.%s\",%d,%s,0.000000,0,1,1", connectionName, tagName, freq, dataType)
+			iot = append(iot, outLine)
+		}
+	}
+	return
+}
@@ -0,0 +1,121 @@
+package tagsgen
+
+// BlockCost - liczba zapytań S7 i przesyłanych bajtów dla podziału obrazu na bloki
+// ================================================================================================
+type BlockCost struct {
+	Requests int
+	Bytes    int
+}
+
+// cost - koszt podziału: narzut na zapytanie + koszt przesłanych bajtów
+// ================================================================================================
+func (c BlockCost) cost(opts Options) float64 {
+	return float64(c.Requests)*opts.RequestCost + float64(c.Bytes)*opts.ByteCost
+}
+
+// BlockStats - porównanie podziału obrazu (IB, MB, QB, DBn) algorytmem zachłannym i optymalnym
+// ================================================================================================
+type BlockStats struct {
+	Name      string
+	Greedy    BlockCost
+	Optimized BlockCost
+}
+
+// spansCost - zliczenie zapytań i bajtów dla listy bloków
+// ================================================================================================
+func spansCost(spans []blockSpan) (c BlockCost) {
+	for _, span := range spans {
+		c.Requests++
+		c.Bytes += span.size
+	}
+	return
+}
+
+// imageEntries - wpisy obrazu zajętości w kolejności adresów (jak w planImageBlocks)
+// ================================================================================================
+func imageEntries(image *memImage) (entries []blockSpan) {
+	for ptr := 0; ptr < len(image); ptr++ {
+		if image[ptr] == 0 {
+			continue
+		}
+		size := int(image[ptr])
+		if ptr+size > len(image) {
+			size = len(image) - ptr
+		}
+		entries = append(entries, blockSpan{start: ptr, size: size})
+		ptr += size - 1
+	}
+	return
+}
+
+// optimizeImageBlocks - podział obrazu zajętości na bloki o minimalnym koszcie
+// Programowanie dynamiczne po kolejnych wpisach: best[j] - minimalny koszt odczytu j pierwszych
+// wpisów, ostatni blok obejmuje wpisy i..j-1 i nie jest większy niż maxSize
+// (chyba że to pojedynczy wpis, np. STRING).
+// ================================================================================================
+func optimizeImageBlocks(image *memImage, maxSize int, requestCost, byteCost float64) (spans []blockSpan) {
+
+	entries := imageEntries(image)
+	if len(entries) == 0 {
+		return
+	}
+
+	best := make([]float64, len(entries)+1)
+	from := make([]int, len(entries)+1)
+
+	for j := 1; j <= len(entries); j++ {
+		end := entries[j-1].start + entries[j-1].size
+
+		best[j] = -1
+		for i := j - 1; i >= 0; i-- {
+			size := end - entries[i].start
+			if size > maxSize && i < j-1 {
+				break
+			}
+			c := best[i] + requestCost + byteCost*float64(size)
+			if best[j] < 0 || c < best[j] {
+				best[j] = c
+				from[j] = i
+			}
+		}
+	}
+
+	for j := len(entries); j > 0; j = from[j] {
+		i := from[j]
+		start := entries[i].start
+		spans = append(spans, blockSpan{start: start, size: entries[j-1].start + entries[j-1].size - start})
+	}
+
+	// odwrócenie - bloki w kolejności adresów
+	for l, r := 0, len(spans)-1; l < r; l, r = l+1, r-1 {
+		spans[l], spans[r] = spans[r], spans[l]
+	}
+
+	return
+}
+
+// planBlocks - podział obrazu na bloki wg opcji; przy optymalizacji zapis porównania z algorytmem
+// zachłannym do p.BlockReport, z poprzednią generacją (p.Baseline) - zachowanie jej bloków
+// ================================================================================================
+func (p *Project) planBlocks(image *memImage, name string, opts Options) []blockSpan {
+
+	if len(p.Baseline) > 0 {
+		return p.planStableBlocks(image, name, opts)
+	}
+
+	greedy := planImageBlocks(image, opts.blockLimit(), opts.GapTolerance)
+	if !opts.Optimize {
+		return greedy
+	}
+
+	optimized := optimizeImageBlocks(image, opts.blockLimit(), opts.RequestCost, opts.ByteCost)
+	if len(greedy) > 0 {
+		p.BlockReport = append(p.BlockReport, BlockStats{
+			Name:      name,
+			Greedy:    spansCost(greedy),
+			Optimized: spansCost(optimized),
+		})
+	}
+
+	return optimized
+}
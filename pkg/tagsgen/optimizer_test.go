@@ -0,0 +1,113 @@
+package tagsgen
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// bruteForceCost - minimalny koszt podziału wpisów na kolejne bloki: wszystkie 2^(n-1) podziały
+// ================================================================================================
+func bruteForceCost(entries []blockSpan, maxSize int, requestCost, byteCost float64) float64 {
+	best := math.Inf(1)
+	n := len(entries)
+	for cuts := 0; cuts < 1<<(n-1); cuts++ {
+		c, first, valid := 0.0, 0, true
+		for j := 0; j < n; j++ {
+			// bit j - blok kończy się na wpisie j
+			if j < n-1 && cuts&(1<<j) == 0 {
+				continue
+			}
+			size := entries[j].start + entries[j].size - entries[first].start
+			if size > maxSize && first < j {
+				valid = false
+				break
+			}
+			c += requestCost + byteCost*float64(size)
+			first = j + 1
+		}
+		if valid && c < best {
+			best = c
+		}
+	}
+	return best
+}
+
+func TestOptimizeImageBlocksBruteForce(t *testing.T) {
+
+	rnd := rand.New(rand.NewSource(1))
+	costs := []struct{ request, byte float64 }{{20, 1}, {1, 1}, {100, 1}, {0, 1}, {1, 0}}
+
+	for round := 0; round < 200; round++ {
+
+		// do 10 wpisów o rozmiarze 1..6 z przerwami 0..12 bajtów, czasem większych od bloku
+		image := new(memImage)
+		addr := rnd.Intn(16)
+		for n := 1 + rnd.Intn(10); n > 0; n-- {
+			size := 1 + rnd.Intn(6)
+			if rnd.Intn(10) == 0 {
+				size = 40
+			}
+			image[addr] = size
+			addr += size + rnd.Intn(13)
+		}
+		entries := imageEntries(image)
+		maxSize := 8 + rnd.Intn(32)
+		cost := costs[round%len(costs)]
+
+		spans := optimizeImageBlocks(image, maxSize, cost.request, cost.byte)
+
+		// bloki po kolei, każdy zaczyna się i kończy na granicy wpisu, pokrywają wszystkie wpisy
+		e := 0
+		for _, span := range spans {
+			if e >= len(entries) || span.start != entries[e].start {
+				t.Fatalf("round %d: spans %v, entries %v", round, spans, entries)
+			}
+			first := e
+			for e < len(entries) && entries[e].start+entries[e].size <= span.start+span.size {
+				e++
+			}
+			if e == first || entries[e-1].start+entries[e-1].size != span.start+span.size {
+				t.Fatalf("round %d: span %v does not end on an entry, entries %v", round, span, entries)
+			}
+			if span.size > maxSize && e-first > 1 {
+				t.Fatalf("round %d: span %v over max size %d", round, span, maxSize)
+			}
+		}
+		if e != len(entries) {
+			t.Fatalf("round %d: spans %v do not cover entries %v", round, spans, entries)
+		}
+
+		got := spansCost(spans).cost(Options{RequestCost: cost.request, ByteCost: cost.byte})
+		want := bruteForceCost(entries, maxSize, cost.request, cost.byte)
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("round %d: cost %v, brute force %v (entries %v, max size %d, spans %v)", round, got, want, entries, maxSize, spans)
+		}
+	}
+}
+
+func TestOptimizeImageBlocks(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		image        *memImage
+		maxSize      int
+		request, bte float64
+		want         []blockSpan
+	}{
+		{"empty", testImage(), 16, 20, 1, nil},
+		{"gap cheaper than request", testImage([2]int{0, 2}, [2]int{10, 2}), 16, 20, 1, []blockSpan{{0, 12}}},
+		{"request cheaper than gap", testImage([2]int{0, 2}, [2]int{30, 2}), 64, 20, 1, []blockSpan{{0, 2}, {30, 2}}},
+		{"max size", testImage([2]int{0, 2}, [2]int{10, 2}), 8, 20, 1, []blockSpan{{0, 2}, {10, 2}}},
+		{"large entry", testImage([2]int{0, 2}, [2]int{2, 40}, [2]int{42, 2}), 16, 20, 1, []blockSpan{{0, 2}, {2, 40}, {42, 2}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := optimizeImageBlocks(tt.image, tt.maxSize, tt.request, tt.bte); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+// Package tagsgen generuje konfigurację tagów Kepware KepServerEX + IoT Gateway
+// na podstawie eksportów symboli i tagów Siemens Step7 / WinCC flexible / TIA Portal.
+package tagsgen
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Tryby generowania tagów Kepware
+// ================================================================================================
+const (
+	ModeBlocks  = "blocks"
+	ModeSymbols = "symbols"
+	ModeHybrid  = "hybrid"
+
+	kepByteArray = "Byte Array"
+)
+
+// Project - dane jednego projektu: symbole, bloki tagów Kepware, tagi i alarmy
+// ================================================================================================
+type Project struct {
+	Symbols  []Symbol
+	KepTags  []KepTag
+	DBBlocks []DBBlock
+	Tags     Tags
+	Alarms   Alarms
+
+	AlarmDefs []AlarmDef
+
+	// UnresolvedAlarms - alarmy pominięte w alarms.json, bo ich taga nie ma w tagach Kepware
+	UnresolvedAlarms []UnresolvedAlarm
+
+	// TextLists - listy tekstów HMI wg nazwy; TextListLinks - powiązania tagów z listami
+	TextLists     map[string]*TextList
+	TextListLinks []TextListLink
+
+	// ParseErrors - pominięte linie plików wejściowych
+	ParseErrors []*ParseError
+
+	// DuplicateSymbols - symbole bez własnego taga (-mode symbols / hybrid), bo tag o tej nazwie
+	// dostał już wcześniejszy symbol
+	DuplicateSymbols []Symbol
+
+	// BlockReport - porównanie podziału na bloki z algorytmem zachłannym (Options.Optimize)
+	BlockReport []BlockStats
+
+	// Baseline - bloki poprzedniej generacji (plc.csv / tags.json), których granice i nazwy
+	// są zachowywane; BlockChanges - zmiany bloków względem nich (bez bloków w trybie symbols -
+	// nie są porównywane)
+	Baseline     []KepTag
+	BlockChanges []BlockChange
+}
+
+// Options - parametry generowania
+// ================================================================================================
+type Options struct {
+	ConnectionName string
+	BlockSize      int
+	ScanRate       int
+	Mode           string
+
+	// PDUSize - wynegocjowany rozmiar PDU sterownika (240, 480, 960), 0 - bez ograniczenia.
+	// Blok nie jest większy niż dane odpowiedzi mieszczące się w PDU (240 -> 222 bajty),
+	// BlockSize 0 oznacza bloki o pełnym rozmiarze PDU.
+	PDUSize int
+
+	// GapTolerance - bloki oddzielone co najwyżej tyloma wolnymi bajtami są łączone
+	GapTolerance int
+
+	// Optimize - podział obrazów na bloki o minimalnym koszcie zamiast algorytmu zachłannego.
+	// Koszt = RequestCost * liczba zapytań + ByteCost * liczba przesłanych bajtów.
+	Optimize    bool
+	RequestCost float64
+	ByteCost    float64
+
+	// Agent - agent IoT Gateway (MQTT Client, REST Client, REST Server) z elementami iot.csv
+	// w formacie Kepware Configuration API; pusty Agent.Type - bez agenta
+	Agent AgentOptions
+
+	// Device - kanał i urządzenie Siemens TCP/IP Ethernet (model, IP, rack/slot, TSAP) dla
+	// nazwy połączenia ConnectionName; pusty Device.IP - bez definicji
+	Device DeviceOptions
+
+	// Languages - języki tekstów alarmów i list tekstów w alarms.json i tags.json (de-DE, pl-PL),
+	// puste - wszystkie języki eksportu; FallbackLanguage - język tekstów wstawianych w miejsce
+	// pustych tłumaczeń, pusty - tłumaczenia pozostają puste
+	Languages        []string
+	FallbackLanguage string
+}
+
+// DefaultOptions - domyślne parametry generowania (jak flagi programu)
+// ================================================================================================
+func DefaultOptions() Options {
+	return Options{
+		ConnectionName: "SiemensTCPIP.PLC",
+		BlockSize:      8,
+		ScanRate:       100,
+		Mode:           ModeBlocks,
+		RequestCost:    50,
+		ByteCost:       1,
+		Agent: AgentOptions{
+			Name:     "tagsgenerator",
+			Topic:    "iotgateway",
+			QoS:      1,
+			ClientID: "tagsgenerator",
+			Rate:     1000,
+			Port:     39320,
+		},
+		Device: DeviceOptions{
+			Model: "S7-300",
+			Port:  102,
+			Slot:  -1,
+		},
+	}
+}
+
+// Result - wynik generowania: linie plików plc.csv i iot.csv oraz opisy tags.json i alarms.json
+// ================================================================================================
+type Result struct {
+	PLC    []string
+	IOT    []string
+	Tags   Tags
+	Alarms Alarms
+
+	BlockReport  []BlockStats
+	BlockChanges []BlockChange
+
+	// UnresolvedAlarms - alarmy, których nie ma w Alarms (Project.UnresolvedAlarms)
+	UnresolvedAlarms []UnresolvedAlarm
+
+	// DuplicateSymbols - symbole pominięte w tagach z typem danych (Project.DuplicateSymbols)
+	DuplicateSymbols []Symbol
+
+	// Agent - definicja agenta IoT Gateway (Options.Agent), nil bez agenta
+	Agent KepObject
+
+	// Channel - definicja kanału z urządzeniem (Options.Device), nil bez definicji
+	Channel KepObject
+}
+
+// NewProject - nowy, pusty projekt
+// ================================================================================================
+func NewProject() *Project {
+	return &Project{}
+}
+
+// Generate - wygenerowanie tagów Kepware, elementów IoT Gateway oraz wskaźników dla symboli i alarmów
+// Może być wywołane wielokrotnie - poprzednie bloki, tagi i alarmy są zastępowane.
+// ================================================================================================
+func (p *Project) Generate(opts Options) (*Result, error) {
+
+	switch opts.Mode {
+	case "":
+		opts.Mode = ModeBlocks
+	case ModeBlocks, ModeSymbols, ModeHybrid:
+	default:
+		return nil, fmt.Errorf("unknown generation mode: %s", opts.Mode)
+	}
+	if opts.PDUSize != 0 && opts.PDUSize <= s7ReadOverhead {
+		return nil, fmt.Errorf("invalid PDU size: %d", opts.PDUSize)
+	}
+	if opts.BlockSize < 0 || (opts.BlockSize == 0 && opts.PDUSize == 0) {
+		return nil, fmt.Errorf("invalid block size: %d", opts.BlockSize)
+	}
+	if opts.GapTolerance < 0 {
+		return nil, fmt.Errorf("invalid gap tolerance: %d", opts.GapTolerance)
+	}
+	if opts.RequestCost < 0 || opts.ByteCost < 0 {
+		return nil, fmt.Errorf("invalid block cost: request %g, byte %g", opts.RequestCost, opts.ByteCost)
+	}
+	if err := opts.Agent.validate(); err != nil {
+		return nil, err
+	}
+	if err := opts.Device.validate(opts.ConnectionName); err != nil {
+		return nil, err
+	}
+
+	p.KepTags = nil
+	p.DBBlocks = nil
+	p.Tags = Tags{}
+	p.BlockReport = nil
+	p.BlockChanges = nil
+	p.DuplicateSymbols = nil
+
+	var res Result
+
+	res.PLC = p.generatePLC(opts)
+	res.BlockReport = p.BlockReport
+	res.DuplicateSymbols = p.DuplicateSymbols
+	if len(p.Baseline) > 0 && opts.Mode != ModeSymbols {
+		p.compareBaseline()
+		res.BlockChanges = p.BlockChanges
+	}
+	res.IOT = generateIOT(res.PLC, opts.ConnectionName, opts.ScanRate)
+	if opts.Agent.Type != "" {
+		res.Agent = generateAgent(res.PLC, opts)
+	}
+	if opts.Device.IP != "" {
+		res.Channel = generateChannel(opts)
+	}
+
+	if len(p.Alarms.SourceFilename) > 0 {
+		p.resolveAlarms(opts)
+	}
+	res.Alarms = p.Alarms
+	res.UnresolvedAlarms = p.UnresolvedAlarms
+
+	p.generateTagsFromSymbols(opts)
+	res.Tags = p.Tags
+
+	return &res, nil
+}
+
+// resolveAlarms - Przetworzenie definicji alarmów na wskaźniki do tablicy tagów
+// ================================================================================================
+func (p *Project) resolveAlarms(opts Options) {
+
+	p.Alarms.ConnectionName = opts.ConnectionName
+	p.Alarms.Timestamp = time.Now().Unix()
+	p.Alarms.Alarms = nil
+	p.UnresolvedAlarms = nil
+
+	// numery alarmów bitowych i analogowych są niezależne (TIA Portal)
+	type alarmID struct {
+		number int
+		analog bool
+	}
+	numbers := make(map[alarmID]bool)
+
+	for _, def := range p.AlarmDefs {
+
+		var tagName string
+		var index, bitNr int
+		var err error
+
+		var analog *AnalogAlarm
+
+		id := alarmID{def.Number, def.Analog}
+		if numbers[id] {
+			err = fmt.Errorf("%w: %d", ErrDuplicateNumber, def.Number)
+		} else if def.Analog {
+			if analog, err = p.resolveAnalogAlarm(def); err == nil {
+				tagName, index = analog.Trigger.TagName, analog.Trigger.Index
+			}
+			bitNr = -1
+		} else {
+			tagName, index, bitNr, err = p.resolveAlarmTrigger(def.TriggerTag, def.TriggerBitNr)
+		}
+		numbers[id] = true
+
+		if err != nil {
+			u := UnresolvedAlarm{Number: def.Number, TriggerTag: def.TriggerTag, BitNr: def.TriggerBitNr, Reason: unresolvedReason(err), Details: err.Error()}
+			if def.Analog {
+				u.BitNr = -1
+			}
+			p.UnresolvedAlarms = append(p.UnresolvedAlarms, u)
+			continue
+		}
+
+		// dodajemy do tablicy alarmów
+		data := CsvAlarm{
+			Number:  def.Number,
+			Texts:   def.Texts.localize(opts.Languages, opts.FallbackLanguage),
+			TagName: tagName,
+			Index:   index,
+			BitNr:   bitNr,
+			Class:   def.Class,
+			Group:   def.Group,
+			Analog:  analog,
+		}
+
+		// potwierdzenia są opcjonalne: alarm zostaje, brakujący wskaźnik trafia do raportu
+		ack := func(name, tag string, bitNr int) *AlarmPointer {
+			ptr, err := p.resolveAlarmPointer(tag, bitNr)
+			if err != nil {
+				err = fmt.Errorf("%w: %s %w", ErrUnresolvedAck, name, err)
+				p.UnresolvedAlarms = append(p.UnresolvedAlarms, UnresolvedAlarm{Number: def.Number, TriggerTag: tag, BitNr: bitNr, Reason: unresolvedReason(err), Details: err.Error()})
+			}
+			return ptr
+		}
+		data.AckHMI = ack("AckHMI", def.AckHMITag, def.AckHMIBitNr)
+		data.AckPLC = ack("AckPLC", def.AckPLCTag, def.AckPLCBitNr)
+		if !def.Infotexts.blank() {
+			data.Infotexts = def.Infotexts.localize(opts.Languages, opts.FallbackLanguage)
+		}
+		p.Alarms.Alarms = append(p.Alarms.Alarms, data)
+	}
+}
+
+// isXlsx - czy plik jest eksportem TIA Portal w formacie xlsx
+// ================================================================================================
+func isXlsx(filename string) bool {
+	return strings.Contains(filename, ".xlsx")
+}
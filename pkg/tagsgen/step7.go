@@ -0,0 +1,198 @@
+package tagsgen
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// parseTIAAddress - rozdzielenie typu i adresu
+// ================================================================================================
+func parseAddress(s string) (letters, numbers string) {
+	var l, n []rune
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			l = append(l, r)
+		case r >= 'a' && r <= 'z':
+			l = append(l, r)
+		case r >= '0' && r <= '9':
+			n = append(n, r)
+		}
+	}
+	return string(l), string(n)
+}
+
+// decodeS7PLCSymLine - rozdzielenie pól w linii
+// ================================================================================================
+func decodeS7PLCSymLine(s string, filename string) (sFieldSym string, sFieldPer string, sFieldNr string, sFieldAddHI string, sFieldAddLO string, sFieldsTyp string, sFieldSize string, sFieldCom string) {
+
+	if strings.Contains(filename, ".asc") {
+
+		startingIndex := strings.Index(s, ",") + 1
+
+		s1 := s[startingIndex:len(s)]
+
+		sFieldSym = s1[0:ascSymbolWidth]
+		sFieldSym = strings.TrimSpace(sFieldSym)
+
+		lineRest := s1[ascSymbolWidth:len(s1)]
+		fields := strings.Fields(lineRest)
+
+		if len(fields) > 0 {
+			sFieldPer = fields[0]
+		}
+		var add string
+		if len(fields) > 1 {
+			add = fields[1]
+		}
+		if len(fields) > 2 {
+			sFieldsTyp = fields[2]
+		}
+
+		if len(fields) > 3 {
+			for i := 3; i < len(fields); i++ {
+				sFieldCom = sFieldCom + fields[i] + " "
+			}
+			sFieldCom = sFieldCom[0 : len(sFieldCom)-1]
+			sFieldCom = strings.TrimRight(sFieldCom, " ")
+		}
+
+		addHILO := strings.Split(add, ".")
+
+		if len(addHILO) > 0 {
+			sFieldAddHI = addHILO[0]
+		}
+
+		if len(addHILO) > 1 {
+			sFieldAddLO = addHILO[1]
+		}
+
+		if sFieldPer == "I" {
+			sFieldSize = "0"
+		}
+		if sFieldPer == "IB" {
+			sFieldSize = "1"
+		}
+		if sFieldPer == "IW" {
+			sFieldSize = "2"
+		}
+		if sFieldPer == "ID" {
+			sFieldSize = "4"
+		}
+
+		if sFieldPer == "M" {
+			sFieldSize = "0"
+		}
+		if sFieldPer == "MB" {
+			sFieldSize = "1"
+		}
+		if sFieldPer == "MW" {
+			sFieldSize = "2"
+		}
+		if sFieldPer == "MD" {
+			sFieldSize = "4"
+		}
+
+		if sFieldPer == "Q" {
+			sFieldSize = "0"
+		}
+		if sFieldPer == "QB" {
+			sFieldSize = "1"
+		}
+		if sFieldPer == "QW" {
+			sFieldSize = "2"
+		}
+		if sFieldPer == "QD" {
+			sFieldSize = "4"
+		}
+
+	}
+	if strings.Contains(filename, ".sdf") {
+
+		fields := strings.Split(s, ",")
+
+		if len(fields) > 1 {
+			var add string
+			fullAdd := strings.ReplaceAll(strings.ReplaceAll(fields[1], "\"", ""), "%", "")
+			addHILO := strings.Split(fullAdd, ".")
+
+			sFieldPer, add = parseAddress(addHILO[0])
+			if len(fields) > 2 {
+				sFieldsTyp = strings.ReplaceAll(fields[2], "\"", "")
+			}
+
+			// fmt.Println(fullAdd, addHILO, sFieldPer, add)
+
+			if len(addHILO) > 0 {
+				sFieldAddHI = add
+			}
+			if len(addHILO) > 1 {
+				sFieldAddLO = addHILO[1]
+			}
+		}
+
+	}
+
+	return
+}
+
+// LoadStep7Symbols - wczytanie tablicy symboli Step7 (Symbols.asc, Windows-1250)
+// ================================================================================================
+func (p *Project) LoadStep7Symbols(r io.Reader) error {
+	return p.loadPLCSymbols(r, "Symbols.asc", ".asc")
+}
+
+// LoadTIASymbols - wczytanie tablicy symboli TIA Portal (PLCTags.sdf)
+// ================================================================================================
+func (p *Project) LoadTIASymbols(r io.Reader) error {
+	return p.loadPLCSymbols(r, "PLCTags.sdf", ".sdf")
+}
+
+// ascSymbolWidth - długość pola nazwy symbolu w Symbols.asc
+// ================================================================================================
+const ascSymbolWidth = 24
+
+// loadPLCSymbols - wczytanie linii tablicy symboli PLC w formacie wskazanym rozszerzeniem pliku
+// ================================================================================================
+func (p *Project) loadPLCSymbols(r io.Reader, name string, ext string) error {
+
+	lines, err := readLines(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	for i, line := range lines {
+
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		line = decodeWindows1250(line)
+
+		if ext == ".asc" && len(line)-strings.Index(line, ",")-1 < ascSymbolWidth {
+			p.parseErrorf(name, i+1, line, ErrMalformedLine, "")
+			continue
+		}
+		if ext == ".sdf" && len(strings.Split(line, ",")) < 2 {
+			p.parseErrorf(name, i+1, line, ErrMalformedLine, "")
+			continue
+		}
+
+		sSymbol, sPer, sNr, sAddHI, sAddLO, sType, sSize, sComment := decodeS7PLCSymLine(line, ext)
+		var newSymbol = Symbol{sSymbol, sPer, sNr, sAddHI, sAddLO, sType, sSize, sComment, sType}
+
+		if sSymbol == "" {
+			p.parseErrorf(name, i+1, line, ErrMalformedLine, "no symbol name")
+			continue
+		}
+		if err := checkAddress(newSymbol); err != nil {
+			p.parseErrorf(name, i+1, line, err, "")
+			continue
+		}
+
+		p.Symbols = append(p.Symbols, newSymbol)
+	}
+
+	return nil
+}
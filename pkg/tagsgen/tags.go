@@ -0,0 +1,256 @@
+package tagsgen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blockType - typ bloku tagów Kepware, do którego trafia symbol o danym typie adresu
+// ================================================================================================
+func blockType(per string) string {
+	switch per {
+	case "I", "IB", "IW", "ID":
+		return "IB"
+	case "M", "MB", "MW", "MD":
+		return "MB"
+	case "Q", "QB", "QW", "QD":
+		return "QB"
+	}
+	return per
+}
+
+// kepElementBits - liczba bitów wartości (elementu tablicy) typu danych Kepware
+// ================================================================================================
+var kepElementBits = map[string]int{
+	"Boolean": 1,
+	"Byte":    8,
+	"Char":    8,
+	"Word":    16,
+	"Short":   16,
+	"DWord":   32,
+	"Long":    32,
+	"Float":   32,
+}
+
+// symbolKepTag - samodzielny tag Kepware z typem danych wygenerowany dla symbolu (ta sama nazwa,
+// obszar i adres - symbol o powtórzonej nazwie nie wskazuje na tag innego symbolu)
+// ================================================================================================
+func (p *Project) symbolKepTag(sym Symbol) (KepTag, bool) {
+	name := symbolTagName(sym)
+	address, err := strconv.Atoi(sym.AddressHi)
+	if err != nil {
+		return KepTag{}, false
+	}
+	for _, t := range p.KepTags {
+		if t.DataType != kepByteArray && t.Name == name && t.Type == blockType(sym.Periph) && t.StartingIndex == address {
+			return t, true
+		}
+	}
+	return KepTag{}, false
+}
+
+// resolveAlarmTrigger - szukamy taga alarmu w tagach HMI i bitu w tablicy wygenerowanej dla PLC
+// Numer bitu liczony jest w słowie (S7 big-endian), stąd zamiana bajtów (^1).
+// Błąd ErrUnknownTag, ErrNotInBlock lub ErrBitOutsideBlock, gdy nie da się go wskazać.
+// ================================================================================================
+func (p *Project) resolveAlarmTrigger(triggerTag string, triggerBitNr int) (tagName string, triggerByte int, bitNr int, err error) {
+
+	for _, sym := range p.Symbols {
+
+		if sym.Name == triggerTag {
+
+			tagAddress, _ := strconv.Atoi(sym.AddressHi)
+
+			// tag z typem danych - numer bitu w wartości (w elemencie dla tablic, Index to numer
+			// elementu o szerokości wg typu danych)
+			if t, ok := p.symbolKepTag(sym); ok {
+				tagName = t.Name
+				if elemType := strings.TrimSuffix(t.DataType, " Array"); elemType != t.DataType {
+					bits := kepElementBits[elemType]
+					if bits == 0 {
+						bits = 16
+					}
+					triggerByte = triggerBitNr / bits
+					bitNr = triggerBitNr % bits
+				} else {
+					bitNr = triggerBitNr
+				}
+				return
+			}
+
+			for _, t := range p.KepTags {
+
+				if t.DataType == kepByteArray && t.StartingIndex == tagAddress && t.Type == blockType(sym.Periph) {
+
+					triggerByte = (triggerBitNr / 8) ^ 1
+					bitNr = triggerBitNr % 8
+
+					if triggerByte < t.Size {
+						tagName = t.Name
+						return
+					}
+					err = fmt.Errorf("%w: bit %d, byte %d of %s[%d]", ErrBitOutsideBlock, triggerBitNr, triggerByte, t.Name, t.Size)
+					return
+				}
+			}
+			err = fmt.Errorf("%w: no block starts at %s %s", ErrNotInBlock, sym.Periph, sym.AddressHi)
+			return
+		}
+	}
+	err = ErrUnknownTag
+	return
+}
+
+// resolveAlarmPointer - bit potwierdzenia alarmu w tablicy tagów (jak bit wyzwalający);
+// nil bez taga, błąd gdy nie ma go w tagach Kepware
+// ================================================================================================
+func (p *Project) resolveAlarmPointer(tag string, bitNr int) (*AlarmPointer, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	tagName, index, bit, err := p.resolveAlarmTrigger(tag, bitNr)
+	if err != nil {
+		return nil, err
+	}
+	return &AlarmPointer{Tag: tag, TagName: tagName, Index: index, BitNr: bit}, nil
+}
+
+// resolveSymbolPointer - szukamy symbolu w tagach HMI i bloku tagów PLC, w którym się znajduje
+// ================================================================================================
+func (p *Project) resolveSymbolPointer(symbolName string) (tagName string, index int, err error) {
+
+	for _, sym := range p.Symbols {
+
+		if sym.Name == symbolName {
+
+			tagAddress, _ := strconv.Atoi(sym.AddressHi)
+
+			if t, ok := p.symbolKepTag(sym); ok {
+				tagName = t.Name
+				return
+			}
+
+			for _, t := range p.KepTags {
+
+				if t.DataType == kepByteArray && tagAddress >= t.StartingIndex && tagAddress < t.StartingIndex+t.Size && t.Type == blockType(sym.Periph) {
+					tagName = t.Name
+					index = tagAddress - t.StartingIndex
+					return
+				}
+			}
+			err = fmt.Errorf("%w: %s %s", ErrNotInBlock, sym.Periph, sym.AddressHi)
+			return
+		}
+	}
+	err = ErrUnknownTag
+	return
+}
+
+// generateTagsFromSymbols - Przetworzenie symboli na wskaźniki do tablicy tagów
+// ================================================================================================
+func (p *Project) generateTagsFromSymbols(opts Options) {
+
+	p.Tags.ConnectionName = opts.ConnectionName
+	p.Tags.Timestamp = time.Now().Unix()
+
+	if len(p.Symbols) > 0 {
+
+		for _, sym := range p.Symbols {
+			// fmt.Println(sym.Name, sym.Type, sym.Periph, sym.AddressHi, sym.AddressLo, sym.Size)
+
+			if sym.Type != "FB" && sym.Type != "DB" && sym.Type != "FC" && sym.Type != "TIMER" && sym.Type != "UDT" {
+
+				var comment []string
+				comment = append(comment, sym.Comment)
+
+				// type CsvTag struct {
+				// 	Index   int
+				// }
+
+				size, _ := strconv.Atoi(sym.Size)
+				loAddress, _ := strconv.Atoi(sym.AddressLo)
+				hiAddress, _ := strconv.Atoi(sym.AddressHi)
+
+				var index int
+				var name string
+
+				// szukamy tego bitu w tablicy wygenerowanej dla PLC
+				// -----------------------------------------------
+
+				found := false
+				bitNr := loAddress
+				dataType := kepByteArray
+
+				// tag z typem danych dla pojedynczego symbolu
+				if t, ok := p.symbolKepTag(sym); ok {
+					bitNr = 0
+					name = t.Name
+					dataType = t.DataType
+					found = true
+				}
+
+				for _, t := range p.KepTags {
+
+					if found {
+						break
+					}
+					if t.DataType != kepByteArray {
+						continue
+					}
+
+					if hiAddress >= t.StartingIndex && hiAddress < t.StartingIndex+t.Size {
+
+						if t.Type == sym.Periph ||
+							t.Type == "IB" && sym.Periph == "I" ||
+							t.Type == "IB" && sym.Periph == "IB" ||
+							t.Type == "IB" && sym.Periph == "IW" ||
+							t.Type == "IB" && sym.Periph == "ID" ||
+							t.Type == "MB" && sym.Periph == "M" ||
+							t.Type == "MB" && sym.Periph == "MB" ||
+							t.Type == "MB" && sym.Periph == "MW" ||
+							t.Type == "MB" && sym.Periph == "MD" ||
+							t.Type == "QB" && sym.Periph == "Q" ||
+							t.Type == "QB" && sym.Periph == "QB" ||
+							t.Type == "QB" && sym.Periph == "QW" ||
+							t.Type == "QB" && sym.Periph == "QD" {
+
+							// fmt.Println("Type: " + t.Type + " vs " + sym.Type + " vs " + sym.Periph)
+
+							index = hiAddress - t.StartingIndex
+							name = t.Name
+							found = true
+							break
+						}
+					}
+
+				}
+
+				if found {
+					data := CsvTag{
+						SymbolName:      sym.Name,
+						SymbolPeriph:    sym.Periph,
+						SymbolAddressHi: sym.AddressHi,
+						SymbolAddressLo: sym.AddressLo,
+						Comment:         sym.Comment,
+						TagName:         name,
+						Size:            size,
+						BitNr:           bitNr,
+						DataType:        dataType,
+						SymbolDataType:  sym.DataType,
+						Index:           index,
+					}
+					if list := p.textListOf(sym.Name); list != nil {
+						data.TextList = list.Name
+						data.Texts = localizeTextList(list.Texts, opts.Languages, opts.FallbackLanguage)
+					}
+
+					p.Tags.Tags = append(p.Tags.Tags, data)
+
+				}
+
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package tagsgen
+
+import "testing"
+
+func TestResolveAlarmTriggerTypedArrays(t *testing.T) {
+
+	p := NewProject()
+	p.Symbols = []Symbol{
+		{Name: "Bytes", Periph: "MB", AddressHi: "0", Size: "4", DataType: "BYTE"},
+		{Name: "Words", Periph: "MW", AddressHi: "10", Size: "4", DataType: "WORD"},
+		{Name: "DWords", Periph: "MD", AddressHi: "20", Size: "8", DataType: "DWORD"},
+		{Name: "Longs", Periph: "DB5", AddressHi: "0", Size: "8", DataType: "DINT"},
+		{Name: "Flags", Periph: "MW", AddressHi: "30", Size: "2", DataType: "WORD"},
+	}
+
+	opts := DefaultOptions()
+	opts.Mode = ModeSymbols
+	if _, err := p.Generate(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tag          string
+		bit          int
+		index, bitNr int
+	}{
+		{"Bytes", 10, 0, 2}, // Byte Array jak blok - bajty słowa S7 zamienione (^1)
+		{"Words", 20, 1, 4},
+		{"DWords", 40, 1, 8},
+		{"Longs", 31, 0, 31},
+		{"Flags", 12, 0, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			tagName, index, bitNr, err := p.resolveAlarmTrigger(tt.tag, tt.bit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tagName != tt.tag || index != tt.index || bitNr != tt.bitNr {
+				t.Errorf("got %s[%d].%d, want %s[%d].%d", tagName, index, bitNr, tt.tag, tt.index, tt.bitNr)
+			}
+		})
+	}
+}
+
+func TestSymbolKepTagMatchesAddress(t *testing.T) {
+
+	// dwa symbole o tej samej nazwie - tag dostaje tylko pierwszy
+	p := NewProject()
+	p.Symbols = []Symbol{
+		{Name: "Status", Periph: "MW", AddressHi: "10", Size: "2", DataType: "WORD"},
+		{Name: "Status", Periph: "DB5", AddressHi: "10", Size: "2", DataType: "WORD"},
+	}
+
+	opts := DefaultOptions()
+	opts.Mode = ModeSymbols
+	res, err := p.Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.DuplicateSymbols) != 1 || res.DuplicateSymbols[0].Periph != "DB5" {
+		t.Errorf("duplicate symbols = %+v", res.DuplicateSymbols)
+	}
+	if _, ok := p.symbolKepTag(p.Symbols[0]); !ok {
+		t.Error("tag of first symbol not found")
+	}
+	if tag, ok := p.symbolKepTag(p.Symbols[1]); ok {
+		t.Errorf("second symbol resolved to tag of first: %+v", tag)
+	}
+	if _, _, _, err := p.resolveAlarmTrigger("Status", 3); err != nil {
+		t.Errorf("first symbol: %v", err)
+	}
+}
+
+func TestResolveAlarmsUnresolvedAck(t *testing.T) {
+
+	p := NewProject()
+	p.Symbols = []Symbol{
+		{Name: "Flags", Periph: "MW", AddressHi: "30", Size: "2", DataType: "WORD"},
+	}
+	p.Alarms.SourceFilename = "alarms.csv"
+	p.AlarmDefs = []AlarmDef{
+		{Number: 1, TriggerTag: "Flags", TriggerBitNr: 0, AckHMITag: "Flags", AckHMIBitNr: 1, AckPLCTag: "Missing", AckPLCBitNr: 2},
+	}
+
+	opts := DefaultOptions()
+	opts.Mode = ModeSymbols
+	if _, err := p.Generate(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// alarm zostaje bez potwierdzenia ze sterownika, tag potwierdzenia jest w raporcie
+	if len(p.Alarms.Alarms) != 1 {
+		t.Fatalf("alarms = %+v", p.Alarms.Alarms)
+	}
+	a := p.Alarms.Alarms[0]
+	if a.AckHMI == nil || a.AckPLC != nil {
+		t.Errorf("AckHMI = %+v, AckPLC = %+v", a.AckHMI, a.AckPLC)
+	}
+	if len(p.UnresolvedAlarms) != 1 {
+		t.Fatalf("unresolved = %+v", p.UnresolvedAlarms)
+	}
+	u := p.UnresolvedAlarms[0]
+	if u.Number != 1 || u.TriggerTag != "Missing" || u.BitNr != 2 || u.Reason != ErrUnresolvedAck.Error() {
+		t.Errorf("unresolved = %+v", u)
+	}
+}
@@ -0,0 +1,216 @@
+package tagsgen
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Rodzaje list tekstów WinCC flexible (kolumna Selection)
+// ================================================================================================
+const (
+	TextListRange = "R" // wartość lub zakres wartości, np. "0", "1-5"
+	TextListBit   = "B" // stan bitu: "0", "1"
+)
+
+// ErrUnknownTextList - powiązanie z listą tekstów, której nie ma w Textlists.csv
+// ================================================================================================
+var ErrUnknownTextList = errors.New("unknown text list")
+
+// TextList - lista tekstów HMI: teksty wartości w kolejnych językach
+// ================================================================================================
+type TextList struct {
+	Name      string
+	Selection string
+
+	// Texts - język (de-DE, pl-PL, ...) -> wartość -> tekst; puste tłumaczenia są pomijane
+	Texts map[string]map[string]string
+}
+
+// TextListLink - powiązanie tagów HMI (wzorzec nazwy z * i ?) z listą tekstów
+// ================================================================================================
+type TextListLink struct {
+	Pattern  string
+	TextList string
+
+	re *regexp.Regexp
+}
+
+// textListName - nazwa do porównania tagu z listą tekstów: ostatni element nazwy bez indeksu
+// tablicy, bez wielkości liter i znaków innych niż litery i cyfry ("AktiveGruppe" = "Aktive_Gruppe")
+// ================================================================================================
+func textListName(name string) string {
+	name = strings.ReplaceAll(name, "\"", "")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// LoadFlexTextLists - wczytanie list tekstów WinCC flexible (Textlists.csv, UTF-16)
+// Linia z kolumną Selection rozpoczyna listę, kolejne linie to wartości z tekstami
+// Entry[de-DE]...Entry[pl-PL] w postaci "de-DE=tekst".
+// ================================================================================================
+func (p *Project) LoadFlexTextLists(r io.Reader, srcFile string) error {
+
+	lines, err := readLinesUTF16(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", srcFile, err)
+	}
+
+	if p.TextLists == nil {
+		p.TextLists = make(map[string]*TextList)
+	}
+
+	for i, line := range lines {
+
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		for f := range fields {
+			fields[f] = strings.ReplaceAll(fields[f], "\"", "")
+		}
+		if len(fields) < 2 || fields[0] == "" {
+			p.parseErrorf(srcFile, i+1, line, ErrMalformedLine, "")
+			continue
+		}
+
+		list, ok := p.TextLists[fields[0]]
+		if !ok {
+			list = &TextList{Name: fields[0], Texts: make(map[string]map[string]string)}
+			p.TextLists[fields[0]] = list
+		}
+
+		// początek listy
+		if fields[1] != "" {
+			list.Selection = fields[1]
+			continue
+		}
+
+		if len(fields) < 5 || fields[3] == "" {
+			p.parseErrorf(srcFile, i+1, line, ErrMalformedLine, "")
+			continue
+		}
+
+		value := strings.ReplaceAll(fields[3], " ", "")
+		for _, entry := range fields[4:] {
+			kv := strings.SplitN(entry, "=", 2)
+			if len(kv) < 2 || kv[1] == "" {
+				continue
+			}
+			if list.Texts[kv[0]] == nil {
+				list.Texts[kv[0]] = make(map[string]string)
+			}
+			list.Texts[kv[0]][value] = kv[1]
+		}
+	}
+
+	return nil
+}
+
+// LoadTextListsFile - wczytanie list tekstów WinCC flexible (Textlists.csv)
+// ================================================================================================
+func (p *Project) LoadTextListsFile(filename string) error {
+	return loadFile(filename, func(r io.Reader) error {
+		return p.LoadFlexTextLists(r, filename)
+	})
+}
+
+// LinkTextList - powiązanie tagów HMI pasujących do wzorca (z * i ?, np. "Status.Roboter*")
+// z listą tekstów; pierwsze pasujące powiązanie wygrywa. Bez powiązań tagi są łączone z listami
+// o tej samej nazwie (ostatni element nazwy taga, bez wielkości liter i znaków "_", "-").
+// ================================================================================================
+func (p *Project) LinkTextList(pattern string, textList string) error {
+
+	if _, ok := p.TextLists[textList]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTextList, textList)
+	}
+	re, err := globPattern(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid tag pattern %q: %w", pattern, err)
+	}
+
+	p.TextListLinks = append(p.TextListLinks, TextListLink{Pattern: pattern, TextList: textList, re: re})
+	return nil
+}
+
+// LoadTextListLinks - wczytanie powiązań tagów z listami tekstów: linie "wzorzec = lista",
+// linie puste i zaczynające się od # są pomijane
+// ================================================================================================
+func (p *Project) LoadTextListLinks(r io.Reader, srcFile string) error {
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) < 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			p.parseErrorf(srcFile, n, line, ErrMalformedLine, "")
+			continue
+		}
+		if err := p.LinkTextList(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])); err != nil {
+			p.parseErrorf(srcFile, n, line, err, "")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", srcFile, err)
+	}
+	return nil
+}
+
+// LoadTextListLinksFile - wczytanie powiązań tagów z listami tekstów z pliku
+// ================================================================================================
+func (p *Project) LoadTextListLinksFile(filename string) error {
+	return loadFile(filename, func(r io.Reader) error {
+		return p.LoadTextListLinks(r, filename)
+	})
+}
+
+// textListOf - lista tekstów taga: z powiązań lub o tej samej nazwie; nil bez listy
+// ================================================================================================
+func (p *Project) textListOf(tagName string) *TextList {
+
+	if len(p.TextLists) == 0 {
+		return nil
+	}
+
+	name := strings.ReplaceAll(tagName, "\"", "")
+
+	if len(p.TextListLinks) > 0 {
+		for _, l := range p.TextListLinks {
+			if l.re.MatchString(name) {
+				return p.TextLists[l.TextList]
+			}
+		}
+		return nil
+	}
+
+	// przy kilku listach o tej samej nazwie - pierwsza alfabetycznie
+	var found *TextList
+	key := textListName(name)
+	for _, list := range p.TextLists {
+		if key != "" && textListName(list.Name) == key && (found == nil || list.Name < found.Name) {
+			found = list
+		}
+	}
+	return found
+}
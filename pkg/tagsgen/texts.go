@@ -0,0 +1,168 @@
+package tagsgen
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// cultureText - tekst z kodem języka w formacie WinCC flexible ("de-DE=tekst")
+// ================================================================================================
+var cultureText = regexp.MustCompile(`^([a-z]{2}-[A-Z]{2})=(.*)$`)
+
+// LangTexts - teksty w kolejnych językach: kod języka (de-DE, pl-PL, ...) -> tekst
+// Puste tłumaczenia są zachowane jako "", dzięki czemu lista języków nie zależy od tłumaczeń.
+// ================================================================================================
+type LangTexts map[string]string
+
+// UnmarshalJSON - odczyt mapy lub listy "de-DE=tekst" z alarms.json poprzednich wersji
+// ================================================================================================
+func (t *LangTexts) UnmarshalJSON(data []byte) error {
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		var m map[string]string
+		if err := json.Unmarshal(data, &m); err != nil {
+			return err
+		}
+		*t = m
+		return nil
+	}
+
+	if list == nil {
+		*t = nil
+		return nil
+	}
+	*t = make(LangTexts)
+	for _, s := range list {
+		if m := cultureText.FindStringSubmatch(s); m != nil {
+			(*t)[m[1]] = m[2]
+		}
+	}
+	return nil
+}
+
+// Languages - kody języków w kolejności alfabetycznej
+// ================================================================================================
+func (t LangTexts) Languages() []string {
+	langs := make([]string, 0, len(t))
+	for lang := range t {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Text - tekst w języku lang; pierwszy niepusty tekst (wg kodu języka), gdy brak tłumaczenia
+// ================================================================================================
+func (t LangTexts) Text(lang string) string {
+	for l, text := range t {
+		if lang != "" && strings.EqualFold(l, lang) && strings.TrimSpace(text) != "" {
+			return strings.TrimSpace(text)
+		}
+	}
+	for _, l := range t.Languages() {
+		if text := strings.TrimSpace(t[l]); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// String - teksty "de-DE=tekst" oddzielone " | " w kolejności języków
+// ================================================================================================
+func (t LangTexts) String() string {
+	var list []string
+	for _, l := range t.Languages() {
+		list = append(list, l+"="+t[l])
+	}
+	return strings.Join(list, " | ")
+}
+
+// Equal - czy teksty są takie same we wszystkich językach
+// ================================================================================================
+func (t LangTexts) Equal(other LangTexts) bool {
+	if len(t) != len(other) {
+		return false
+	}
+	for l, text := range t {
+		if o, ok := other[l]; !ok || o != text {
+			return false
+		}
+	}
+	return true
+}
+
+// blank - czy wszystkie tłumaczenia są puste
+// ================================================================================================
+func (t LangTexts) blank() bool {
+	for _, text := range t {
+		if strings.TrimSpace(text) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// localize - wybrane języki (wszystkie, gdy langs jest puste); puste tłumaczenia uzupełniane
+// tekstem w języku fallback
+// ================================================================================================
+func (t LangTexts) localize(langs []string, fallback string) LangTexts {
+
+	if len(t) == 0 {
+		return t
+	}
+	if len(langs) == 0 {
+		langs = t.Languages()
+	}
+
+	out := make(LangTexts)
+	for _, l := range langs {
+		text, ok := t[l]
+		if strings.TrimSpace(text) == "" && fallback != "" {
+			if f := t[fallback]; strings.TrimSpace(f) != "" {
+				text, ok = f, true
+			}
+		}
+		if ok {
+			out[l] = text
+		}
+	}
+	return out
+}
+
+// localizeTextList - teksty listy w wybranych językach; wartość bez tłumaczenia lub z pustym
+// tłumaczeniem dostaje tekst w języku fallback (jak teksty alarmów)
+// ================================================================================================
+func localizeTextList(texts map[string]map[string]string, langs []string, fallback string) map[string]map[string]string {
+
+	if len(langs) == 0 && fallback == "" {
+		return texts
+	}
+	if len(langs) == 0 {
+		for l := range texts {
+			langs = append(langs, l)
+		}
+	}
+
+	out := make(map[string]map[string]string)
+	for _, l := range langs {
+		values := make(map[string]string)
+		for v, text := range texts[fallback] {
+			if strings.TrimSpace(text) != "" {
+				values[v] = text
+			}
+		}
+		for v, text := range texts[l] {
+			if _, ok := values[v]; ok && strings.TrimSpace(text) == "" {
+				continue
+			}
+			values[v] = text
+		}
+		if len(values) > 0 {
+			out[l] = values
+		}
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package tagsgen
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLocalizeTextList(t *testing.T) {
+
+	texts := map[string]map[string]string{
+		"de-DE": {"0": "Aus", "1": "Ein", "2": ""},
+		"pl-PL": {"0": "Wył", "1": "", "2": " "},
+	}
+
+	tests := []struct {
+		name     string
+		langs    []string
+		fallback string
+		want     map[string]map[string]string
+	}{
+		{
+			name:     "empty translation gets fallback text",
+			langs:    []string{"pl-PL"},
+			fallback: "de-DE",
+			want:     map[string]map[string]string{"pl-PL": {"0": "Wył", "1": "Ein", "2": " "}},
+		},
+		{
+			name:     "missing language gets fallback texts",
+			langs:    []string{"en-US"},
+			fallback: "de-DE",
+			want:     map[string]map[string]string{"en-US": {"0": "Aus", "1": "Ein"}},
+		},
+		{
+			name:  "without fallback",
+			langs: []string{"pl-PL"},
+			want:  map[string]map[string]string{"pl-PL": {"0": "Wył", "1": "", "2": " "}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := localizeTextList(texts, tt.langs, tt.fallback); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// ten sam wynik co dla tekstów alarmów
+	alarm := LangTexts{"de-DE": "Ein", "pl-PL": ""}.localize([]string{"pl-PL"}, "de-DE")
+	list := localizeTextList(map[string]map[string]string{"de-DE": {"1": "Ein"}, "pl-PL": {"1": ""}}, []string{"pl-PL"}, "de-DE")
+	if alarm["pl-PL"] != list["pl-PL"]["1"] {
+		t.Errorf("alarm text %q, text list %q", alarm["pl-PL"], list["pl-PL"]["1"])
+	}
+}
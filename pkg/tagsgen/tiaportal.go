@@ -0,0 +1,393 @@
+package tagsgen
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Nazwy arkuszy i kolumn w eksportach TIA Portal
+// ================================================================================================
+const (
+	tiaHmiTagsSheet       = "Hmi Tags"
+	tiaDiscreteAlarmSheet = "DiscreteAlarms"
+	tiaAnalogAlarmSheet   = "AnalogAlarms"
+
+	tiaColName       = "Name"
+	tiaColConnection = "Connection"
+	tiaColPLCTag     = "PLC tag"
+	tiaColDataType   = "DataType"
+	tiaColAddress    = "Address"
+	tiaColLength     = "Length"
+	tiaColComment    = "Comment"
+
+	tiaColID         = "ID"
+	tiaColTriggerTag = "Trigger tag"
+	tiaColTriggerBit = "Trigger bit"
+	tiaColAlarmText  = "Alarm text"
+	tiaColInfoText   = "Info text"
+	tiaColLimit      = "Limit"
+	tiaColLimitMode  = "Limit mode"
+	tiaColHyst       = "Hysteresis"
+	tiaColHystPct    = "Hysteresis in %"
+	tiaColHystMode   = "Hysteresis mode"
+	tiaColClass      = "Class"
+	tiaColGroup      = "Group"
+	tiaColAckHMITag  = "Acknowledgement tag"
+	tiaColAckHMIBit  = "Acknowledgement bit"
+	tiaColAckPLCTag  = "PLC acknowledgement tag"
+	tiaColAckPLCBit  = "PLC acknowledgement bit"
+)
+
+// readXlsxSheet - odczyt arkusza xlsx jako tablicy wierszy
+// ================================================================================================
+func readXlsxSheet(r io.Reader, sheet string) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return f.GetRows(sheet)
+}
+
+// xlsxColumns - mapa nazwa kolumny -> indeks na podstawie wiersza nagłówka
+// Kolumny z dopiskiem języka (np. "Comment [de-DE]") są dostępne również pod samą nazwą.
+// ================================================================================================
+func xlsxColumns(header []string) map[string]int {
+	cols := make(map[string]int)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if _, ok := cols[name]; !ok {
+			cols[name] = i
+		}
+		if n := strings.Index(name, " ["); n > 0 {
+			if _, ok := cols[name[:n]]; !ok {
+				cols[name[:n]] = i
+			}
+		}
+	}
+	return cols
+}
+
+// xlsxCell - wartość komórki wiersza z kolumny o podanej nazwie
+// ================================================================================================
+func xlsxCell(row []string, cols map[string]int, name string) string {
+	i, ok := cols[name]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// periphSize - rozmiar w bajtach na podstawie typu adresu (I, IB, IW, ID, DBX, DBB, DBW, DBD ...)
+// ================================================================================================
+func periphSize(per string) string {
+	if strings.HasPrefix(per, "DB") && len(per) > 2 {
+		per = per[2:]
+		if per == "X" {
+			return "0"
+		}
+	} else if len(per) == 1 {
+		return "0"
+	} else if len(per) == 0 {
+		return ""
+	}
+
+	switch per[len(per)-1] {
+	case 'B':
+		return "1"
+	case 'W':
+		return "2"
+	case 'D':
+		return "4"
+	}
+	return ""
+}
+
+// decodeTIAAddress - rozdzielenie adresu absolutnego TIA Portal (%DB100.DBX26.0, %IW124, %M10.2)
+// ================================================================================================
+func decodeTIAAddress(address string) (sFieldPer string, sNr string, sFieldAddHI string, sFieldAddLO string, sFieldsTyp string) {
+
+	address = strings.TrimPrefix(strings.TrimSpace(address), "%")
+	parts := strings.Split(address, ".")
+
+	if strings.HasPrefix(parts[0], "DB") {
+		// ----------------------------------------------------
+		// DB100.DBX26.0
+		// ----------------------------------------------------
+		if len(parts) < 2 {
+			return
+		}
+		_, nr := parseAddress(parts[0])
+		typ, add := parseAddress(parts[1])
+		if nr == "" || add == "" || !strings.HasPrefix(typ, "DB") {
+			return
+		}
+
+		sFieldPer = "DB" + nr
+		sNr = nr
+		sFieldsTyp = typ
+		sFieldAddHI = add
+		if len(parts) > 2 {
+			sFieldAddLO = parts[2]
+		}
+		return
+	}
+
+	// ----------------------------------------------------
+	// I10.6, IW124, MD20, Q483.4
+	// ----------------------------------------------------
+	per, add := parseAddress(parts[0])
+	if add == "" {
+		return
+	}
+	switch per {
+	case "I", "IB", "IW", "ID", "M", "MB", "MW", "MD", "Q", "QB", "QW", "QD":
+		sFieldPer = per
+		sFieldAddHI = add
+		if len(parts) > 1 {
+			sFieldAddLO = parts[1]
+		}
+	}
+	return
+}
+
+// tiaArrayType - typ tablicowy TIA Portal, np. "Array[0..31] of Word"
+// ================================================================================================
+var tiaArrayType = regexp.MustCompile(`^Array\[(-?\d+)\.\.(-?\d+)\] of (.+)$`)
+
+// LoadTIAHmiTags - odczyt tagów HMI z eksportu TIA Portal (HMITags.xlsx, arkusz "Hmi Tags")
+// Tagi bez adresu absolutnego (dostęp symboliczny) dostają adres symbolu z kolumny "PLC tag"
+// z wczytanej wcześniej tablicy symboli; bez takiego symbolu są pomijane.
+// ================================================================================================
+func (p *Project) LoadTIAHmiTags(r io.Reader) error {
+	return p.loadTIAHmiTags(r, "HMITags.xlsx")
+}
+
+// loadTIAHmiTags - odczyt tagów HMI z eksportu TIA Portal; wiersze bez adresu i bez symbolu
+// PLC są pomijane
+// ================================================================================================
+func (p *Project) loadTIAHmiTags(r io.Reader, name string) error {
+
+	rows, err := readXlsxSheet(r, tiaHmiTagsSheet)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("%s: empty sheet %q", name, tiaHmiTagsSheet)
+	}
+
+	cols := xlsxColumns(rows[0])
+	if _, ok := cols[tiaColName]; !ok {
+		return fmt.Errorf("%s: no %q column in sheet %q", name, tiaColName, tiaHmiTagsSheet)
+	}
+	if _, ok := cols[tiaColAddress]; !ok {
+		return fmt.Errorf("%s: no %q column in sheet %q", name, tiaColAddress, tiaHmiTagsSheet)
+	}
+
+	for i, row := range rows[1:] {
+
+		sSymbol := xlsxCell(row, cols, tiaColName)
+		if sSymbol == "" {
+			continue
+		}
+
+		// Tagi wewnętrzne HMI nie mają połączenia ze sterownikiem
+		connection := xlsxCell(row, cols, tiaColConnection)
+		if connection == "" || strings.HasPrefix(connection, "<") {
+			continue
+		}
+
+		address := xlsxCell(row, cols, tiaColAddress)
+		sPer, sNr, sAddHI, sAddLO, sType := decodeTIAAddress(address)
+		if address == "" {
+			plcTag := strings.ReplaceAll(xlsxCell(row, cols, tiaColPLCTag), "\"", "")
+			if plcTag == "" {
+				p.parseErrorf(name, i+2, "", ErrNoAbsoluteAddress, "%s", sSymbol)
+			} else if !p.addPLCTagSymbol(sSymbol, plcTag, xlsxCell(row, cols, tiaColComment)) {
+				p.parseErrorf(name, i+2, "", ErrNoAbsoluteAddress, "%s PLC tag %q", sSymbol, plcTag)
+			}
+			continue
+		}
+		if sPer == "" {
+			p.parseErrorf(name, i+2, "", ErrUnsupportedAddress, "%s %q", sSymbol, address)
+			continue
+		}
+
+		var sSize string
+		if sType != "" {
+			sSize = periphSize(sType)
+		} else {
+			sSize = periphSize(sPer)
+		}
+
+		// Stringi i tablice - rozmiar w bajtach na podstawie typu danych i kolumny Length
+		sDataType := xlsxCell(row, cols, tiaColDataType)
+		length, _ := strconv.Atoi(xlsxCell(row, cols, tiaColLength))
+		count := 0
+		if m := tiaArrayType.FindStringSubmatch(sDataType); m != nil {
+			lo, _ := strconv.Atoi(m[1])
+			hi, _ := strconv.Atoi(m[2])
+			count = hi - lo + 1
+			sDataType = m[3]
+		}
+		sSize = arraySize(sSize, sDataType, length, count)
+
+		sComment := xlsxCell(row, cols, tiaColComment)
+
+		newSymbol := Symbol{sSymbol, sPer, sNr, sAddHI, sAddLO, sType, sSize, sComment, sDataType}
+		if err := checkAddress(newSymbol); err != nil {
+			p.parseErrorf(name, i+2, "", err, "%s %q", sSymbol, address)
+			continue
+		}
+
+		p.Symbols = append(p.Symbols, newSymbol)
+	}
+
+	return nil
+}
+
+// addPLCTagSymbol - tag HMI z dostępem symbolicznym pod adresem symbolu PLC o nazwie plcTag;
+// false, gdy nie ma takiego symbolu. Tag o nazwie symbolu PLC jest już w tablicy symboli.
+// ================================================================================================
+func (p *Project) addPLCTagSymbol(hmiTag string, plcTag string, comment string) bool {
+	for _, sym := range p.Symbols {
+		if sym.Name != plcTag {
+			continue
+		}
+		if hmiTag != plcTag {
+			sym.Name = hmiTag
+			if comment != "" {
+				sym.Comment = comment
+			}
+			p.Symbols = append(p.Symbols, sym)
+		}
+		return true
+	}
+	return false
+}
+
+// tiaTextColumn - kolumny tekstów w językach projektu, np. "Alarm text [de-DE], Alarm text"
+// ================================================================================================
+var tiaTextColumn = regexp.MustCompile(`^(.+) \[([a-z]{2}-[A-Z]{2})\]`)
+
+// tiaTexts - teksty ze wszystkich kolumn językowych (również puste); nil bez takich kolumn
+// ================================================================================================
+func tiaTexts(header []string, row []string, name string) (texts LangTexts) {
+	for i, h := range header {
+		m := tiaTextColumn.FindStringSubmatch(strings.TrimSpace(h))
+		if m == nil || m[1] != name {
+			continue
+		}
+		if texts == nil {
+			texts = make(LangTexts)
+		}
+		texts[m[2]] = ""
+		if i < len(row) {
+			texts[m[2]] = strings.TrimSpace(row[i])
+		}
+	}
+	return
+}
+
+// LoadTIAAlarms - odczyt alarmów z TIA Portal (HMIAlarms.xlsx, arkusze "DiscreteAlarms" i "AnalogAlarms")
+// Alarmy analogowe nie mają bitu wyzwalającego - BitNr = -1, Index wskazuje na tag wyzwalający.
+// ================================================================================================
+func (p *Project) LoadTIAAlarms(r io.Reader, srcFile string) error {
+
+	p.Alarms.SourceFilename = srcFile
+	p.Alarms.SourceInfo = "TIA Portal " + tiaDiscreteAlarmSheet + ", " + tiaAnalogAlarmSheet
+
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", srcFile, err)
+	}
+	defer f.Close()
+
+	for _, sheet := range []string{tiaDiscreteAlarmSheet, tiaAnalogAlarmSheet} {
+
+		// arkusz może nie istnieć, jeżeli projekt nie ma alarmów danego rodzaju
+		if idx, _ := f.GetSheetIndex(sheet); idx < 0 {
+			continue
+		}
+
+		rows, err := f.GetRows(sheet)
+		if err != nil {
+			return fmt.Errorf("%s: %w", srcFile, err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		header := rows[0]
+		cols := xlsxColumns(header)
+
+		for i, row := range rows[1:] {
+
+			id := xlsxCell(row, cols, tiaColID)
+			if id == "" {
+				continue
+			}
+			alarmNumber, err := strconv.Atoi(id)
+			if err != nil {
+				p.parseErrorf(srcFile, i+2, "", ErrInvalidNumber, "%s %s", sheet, id)
+				continue
+			}
+
+			def := AlarmDef{
+				Number:     alarmNumber,
+				TriggerTag: strings.Trim(xlsxCell(row, cols, tiaColTriggerTag), "\""),
+				Analog:     sheet == tiaAnalogAlarmSheet,
+				Texts:      tiaTexts(header, row, tiaColAlarmText),
+				Infotexts:  tiaTexts(header, row, tiaColInfoText),
+				Class:      xlsxCell(row, cols, tiaColClass),
+				Group:      xlsxCell(row, cols, tiaColGroup),
+				AckHMITag:  strings.Trim(xlsxCell(row, cols, tiaColAckHMITag), "\""),
+				AckPLCTag:  strings.Trim(xlsxCell(row, cols, tiaColAckPLCTag), "\""),
+			}
+
+			// bit wyzwalający (wymagany dla alarmów bitowych) i bity potwierdzenia
+			valid := true
+			for _, bit := range []struct {
+				col      string
+				nr       *int
+				required bool
+			}{
+				{tiaColTriggerBit, &def.TriggerBitNr, true},
+				{tiaColAckHMIBit, &def.AckHMIBitNr, false},
+				{tiaColAckPLCBit, &def.AckPLCBitNr, false},
+			} {
+				if bit.nr == &def.TriggerBitNr && def.Analog {
+					continue
+				}
+				cell := xlsxCell(row, cols, bit.col)
+				if *bit.nr, err = parseBitNr(cell, bit.required); err != nil {
+					p.parseErrorf(srcFile, i+2, "", err, "%s %d %s %q", sheet, alarmNumber, bit.col, cell)
+					valid = false
+					break
+				}
+			}
+			if !valid {
+				continue
+			}
+
+			if def.Analog {
+				err := analogAlarmDef(&def, xlsxCell(row, cols, tiaColLimit), xlsxCell(row, cols, tiaColLimitMode), xlsxCell(row, cols, tiaColHyst),
+					xlsxCell(row, cols, tiaColHystPct), xlsxCell(row, cols, tiaColHystMode))
+				if err != nil {
+					p.parseErrorf(srcFile, i+2, "", err, "%s %d", sheet, alarmNumber)
+					continue
+				}
+			}
+
+			p.AlarmDefs = append(p.AlarmDefs, def)
+		}
+	}
+
+	return nil
+}
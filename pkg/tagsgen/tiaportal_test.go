@@ -0,0 +1,220 @@
+package tagsgen
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxFile - skoroszyt eksportu TIA Portal z podanymi arkuszami (pierwszy wiersz - nagłówek)
+// ================================================================================================
+func xlsxFile(t *testing.T, sheets map[string][][]string) *bytes.Reader {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	for name, rows := range sheets {
+		if _, err := f.NewSheet(name); err != nil {
+			t.Fatal(err)
+		}
+		for i, row := range rows {
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			if err := f.SetSheetRow(name, cell, &row); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestLoadTIAAnalogAlarmLimits(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		rows  [][]string
+		limit string
+		mode  string
+	}{
+		{"limit and mode", [][]string{
+			{"ID", "Trigger tag", "Limit", "Limit mode"},
+			{"1", "Level", "80", "Higher"},
+		}, "80", AnalogAbove},
+		{"limit tag", [][]string{
+			{"ID", "Trigger tag", "Limit", "Limit mode"},
+			{"1", "Level", "\"LevelMax\"", "Lower or equal"},
+		}, "LevelMax", AnalogBelowOrEqual},
+		{"no limit column", [][]string{
+			{"ID", "Trigger tag", "Limit mode"},
+			{"1", "Level", "Higher"},
+		}, "", ""},
+		{"empty limit", [][]string{
+			{"ID", "Trigger tag", "Limit", "Limit mode"},
+			{"1", "Level", "", "Higher"},
+		}, "", ""},
+		{"no limit mode column", [][]string{
+			{"ID", "Trigger tag", "Limit"},
+			{"1", "Level", "80"},
+		}, "", ""},
+		{"empty limit mode", [][]string{
+			{"ID", "Trigger tag", "Limit", "Limit mode"},
+			{"1", "Level", "80", ""},
+		}, "", ""},
+		{"invalid limit mode", [][]string{
+			{"ID", "Trigger tag", "Limit", "Limit mode"},
+			{"1", "Level", "80", "Sideways"},
+		}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProject()
+			if err := p.LoadTIAAlarms(xlsxFile(t, map[string][][]string{tiaAnalogAlarmSheet: tt.rows}), "HMIAlarms.xlsx"); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.mode == "" {
+				if len(p.AlarmDefs) != 0 || len(p.ParseErrors) != 1 || !errors.Is(p.ParseErrors[0], ErrInvalidAnalogAlarm) {
+					t.Fatalf("alarms = %+v, parse errors = %v", p.AlarmDefs, p.ParseErrors)
+				}
+				if e := p.ParseErrors[0]; e.File != "HMIAlarms.xlsx" || e.Line != 2 {
+					t.Errorf("error position %s:%d", e.File, e.Line)
+				}
+				return
+			}
+			if len(p.AlarmDefs) != 1 || len(p.ParseErrors) != 0 {
+				t.Fatalf("alarms = %+v, parse errors = %v", p.AlarmDefs, p.ParseErrors)
+			}
+			if d := p.AlarmDefs[0]; !d.Analog || d.Limit != tt.limit || d.LimitMode != tt.mode {
+				t.Errorf("alarm = %+v", d)
+			}
+		})
+	}
+}
+
+func TestLoadTIAHmiTags(t *testing.T) {
+
+	p := NewProject()
+	p.Symbols = []Symbol{
+		{Name: "StartSwitch", Periph: "I", AddressHi: "10", AddressLo: "2", Size: "1", DataType: "Bool"},
+		{Name: "AirPress", Periph: "IW", AddressHi: "124", Size: "2", DataType: "UInt"},
+	}
+
+	header := []string{"Name", "Path", "Connection", "PLC tag", "DataType", "Length", "Address", "Comment [de-DE]"}
+	err := p.LoadTIAHmiTags(xlsxFile(t, map[string][][]string{tiaHmiTagsSheet: {
+		header,
+		{"Speed", "Default tag table", "PLC_1", "", "Int", "2", "%DB10.DBW4", "Drehzahl"},
+		{"Words", "Default tag table", "PLC_1", "", "Array[0..199] of Word", "2", "%DB10.DBW100", ""},
+		{"Internal", "Default tag table", "<No connection>", "", "Int", "2", "", ""},
+		{"Start", "Default tag table", "PLC_1", "\"StartSwitch\"", "Bool", "1", "", "Start"},
+		{"AirPress", "Default tag table", "PLC_1", "\"AirPress\"", "UInt", "2", "", ""},
+		{"Motor", "Default tag table", "PLC_1", "\"Data\".Motor", "Bool", "1", "", ""},
+		{"Symbolic", "Default tag table", "PLC_1", "", "Bool", "1", "", ""},
+		{"Timer", "Default tag table", "PLC_1", "", "Int", "2", "%T5", ""},
+	}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// symbole PLC, tagi z adresem absolutnym i tag Start z adresem symbolu StartSwitch;
+	// tag AirPress ma nazwę symbolu PLC i nie jest dodawany drugi raz
+	want := []Symbol{
+		p.Symbols[0],
+		p.Symbols[1],
+		{Name: "Speed", Periph: "DB10", Nr: "10", AddressHi: "4", Type: "DBW", Size: "2", Comment: "Drehzahl", DataType: "Int"},
+		{Name: "Words", Periph: "DB10", Nr: "10", AddressHi: "100", Type: "DBW", Size: "400", DataType: "Word"},
+		{Name: "Start", Periph: "I", AddressHi: "10", AddressLo: "2", Size: "1", Comment: "Start", DataType: "Bool"},
+	}
+	if len(p.Symbols) != len(want) {
+		t.Fatalf("symbols = %+v", p.Symbols)
+	}
+	for i := range want {
+		if p.Symbols[i] != want[i] {
+			t.Errorf("symbol %d = %+v, want %+v", i, p.Symbols[i], want[i])
+		}
+	}
+
+	// Motor - symbolu PLC nie ma w tablicy symboli, Symbolic - bez taga PLC, Timer - adres T
+	wantErrs := []struct {
+		line int
+		err  error
+	}{
+		{7, ErrNoAbsoluteAddress},
+		{8, ErrNoAbsoluteAddress},
+		{9, ErrUnsupportedAddress},
+	}
+	if len(p.ParseErrors) != len(wantErrs) {
+		t.Fatalf("parse errors = %v", p.ParseErrors)
+	}
+	for i, w := range wantErrs {
+		if e := p.ParseErrors[i]; e.File != "HMITags.xlsx" || e.Line != w.line || !errors.Is(e, w.err) {
+			t.Errorf("parse error %v, want line %d %v", e, w.line, w.err)
+		}
+	}
+}
+
+func TestLoadTIAAlarms(t *testing.T) {
+
+	p := NewProject()
+	err := p.LoadTIAAlarms(xlsxFile(t, map[string][][]string{
+		tiaDiscreteAlarmSheet: {
+			{"ID", "Name", "Alarm text [de-DE], Alarm text", "Alarm text [pl-PL], Alarm text", "Class", "Trigger tag", "Trigger bit", "Acknowledgement tag", "Acknowledgement bit", "PLC acknowledgement tag", "PLC acknowledgement bit", "Group", "Info text [de-DE], Info text"},
+			{"1", "Alarm_1", "Not-Aus", "Wyłącznik awaryjny", "Errors", "\"Alarms\"", "3", "\"AckHMI\"", "1", "\"AckPLC\"", "2", "Safety", "Not-Aus entriegeln"},
+			{"2", "Alarm_2", "Tür offen", "", "Warnings", "\"Alarms\"", "17", "", "", "", "", "", ""},
+			{"x", "Alarm_3", "Falsche Nummer", "", "Errors", "\"Alarms\"", "0", "", "", "", "", "", ""},
+			{"4", "Alarm_4", "Ohne Bit", "", "Errors", "\"Alarms\"", "", "", "", "", "", "", ""},
+			{"5", "Alarm_5", "Falsches Quittierbit", "", "Errors", "\"Alarms\"", "1", "\"AckHMI\"", "y", "", "", "", ""},
+		},
+		tiaAnalogAlarmSheet: {
+			{"ID", "Name", "Alarm text [de-DE], Alarm text", "Class", "Trigger tag", "Limit", "Limit mode", "Hysteresis", "Hysteresis mode"},
+			{"1", "Level_high", "Füllstand hoch", "Warnings", "\"Level\"", "80", "Higher", "2", "On coming and on going"},
+		},
+	}), "HMIAlarms.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.AlarmDefs) != 3 {
+		t.Fatalf("alarms = %+v", p.AlarmDefs)
+	}
+	d := p.AlarmDefs[0]
+	if d.Number != 1 || d.TriggerTag != "Alarms" || d.TriggerBitNr != 3 || d.Analog ||
+		d.Class != "Errors" || d.Group != "Safety" ||
+		d.AckHMITag != "AckHMI" || d.AckHMIBitNr != 1 || d.AckPLCTag != "AckPLC" || d.AckPLCBitNr != 2 {
+		t.Errorf("alarm 1 = %+v", d)
+	}
+	if d.Texts["de-DE"] != "Not-Aus" || d.Texts["pl-PL"] != "Wyłącznik awaryjny" || d.Infotexts["de-DE"] != "Not-Aus entriegeln" {
+		t.Errorf("alarm 1 texts = %v, infotexts = %v", d.Texts, d.Infotexts)
+	}
+	if d := p.AlarmDefs[1]; d.Number != 2 || d.TriggerBitNr != 17 || d.AckHMITag != "" || d.Texts["pl-PL"] != "" {
+		t.Errorf("alarm 2 = %+v", d)
+	}
+
+	// alarm analogowy o tym samym numerze co bitowy
+	d = p.AlarmDefs[2]
+	if d.Number != 1 || !d.Analog || d.TriggerTag != "Level" || d.Limit != "80" || d.LimitMode != AnalogAbove ||
+		d.Hysteresis != 2 || d.HysteresisMode != HysteresisBoth {
+		t.Errorf("analog alarm 1 = %+v", d)
+	}
+
+	wantErrs := []struct {
+		line int
+		err  error
+	}{
+		{4, ErrInvalidNumber},
+		{5, ErrInvalidBitNumber},
+		{6, ErrInvalidBitNumber},
+	}
+	if len(p.ParseErrors) != len(wantErrs) {
+		t.Fatalf("parse errors = %v", p.ParseErrors)
+	}
+	for i, w := range wantErrs {
+		if e := p.ParseErrors[i]; e.File != "HMIAlarms.xlsx" || e.Line != w.line || !errors.Is(e, w.err) {
+			t.Errorf("parse error %v, want line %d %v", e, w.line, w.err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package tagsgen
+
+// CsvAlarm - typ przechowujący dane o alarmach
+// ================================================================================================
+type CsvAlarm struct {
+	Number  int
+	TagName string
+	Index   int
+	BitNr   int
+
+	// Texts, Infotexts - tekst alarmu i tekst pomocy: język -> tekst
+	Texts     LangTexts
+	Infotexts LangTexts `json:",omitempty"`
+
+	// Class, Group - klasa alarmu (np. Errors, Warnings) i grupa potwierdzanych razem alarmów
+	Class string `json:",omitempty"`
+	Group string `json:",omitempty"`
+
+	// AckHMI - bit ustawiany przez HMI przy potwierdzeniu alarmu; AckPLC - bit, którym sterownik
+	// potwierdza alarm; nil bez taga potwierdzenia
+	AckHMI *AlarmPointer `json:",omitempty"`
+	AckPLC *AlarmPointer `json:",omitempty"`
+
+	// Analog - warunek alarmu analogowego (BitNr = -1), nil dla alarmów bitowych
+	Analog *AnalogAlarm `json:",omitempty"`
+}
+
+// AlarmPointer - bit taga HMI w tablicy tagów Kepware
+// ================================================================================================
+type AlarmPointer struct {
+	Tag     string
+	TagName string
+	Index   int
+	BitNr   int
+}
+
+// Alarms - typ przechowujący dane o alarmach
+// ================================================================================================
+type Alarms struct {
+	ConnectionName string
+	Timestamp      int64
+	SourceFilename string
+	SourceInfo     string
+	Alarms         []CsvAlarm
+}
+
+// CsvTag - typ przechowujący dane o alarmach
+// ================================================================================================
+type CsvTag struct {
+	SymbolName      string
+	SymbolPeriph    string
+	SymbolAddressHi string
+	SymbolAddressLo string
+	SymbolDataType  string
+	Comment         string
+	TagName         string
+	DataType        string
+	Index           int
+	BitNr           int
+	Size            int
+
+	// TextList, Texts - lista tekstów HMI taga: język -> wartość -> tekst
+	TextList string                       `json:",omitempty"`
+	Texts    map[string]map[string]string `json:",omitempty"`
+}
+
+// Tags - typ przechowujący dane o alarmach
+// ================================================================================================
+type Tags struct {
+	ConnectionName string
+	Timestamp      int64
+	SourceFilename string
+	SourceInfo     string
+	Tags           []CsvTag
+}
+
+// KepTag - tagi - odwzorowanie linii taga w wartości
+// ================================================================================================
+type KepTag struct {
+	Name          string
+	Type          string
+	DataType      string
+	StartingIndex int
+	Size          int
+}
+
+// Symbol - typ przechowujący dane o symbolu
+// ================================================================================================
+type Symbol struct {
+	Name, Periph, Nr, AddressHi, AddressLo, Type, Size, Comment, DataType string
+}
+
+// memImage - obraz zajętości obszaru pamięci: adres -> rozmiar wpisu w bajtach (0 - wolny);
+// tablice i stringi mogą zajmować więcej niż 255 bajtów
+// ================================================================================================
+type memImage [65536]int
+
+// DBBlock - typ przechowujący dane o bloku DB
+// ================================================================================================
+type DBBlock struct {
+	Nr  int
+	Tab memImage
+}
+
+// AlarmDef - definicja alarmu z eksportu HMI przed rozwiązaniem wskaźnika do tablicy tagów
+// Alarmy analogowe nie mają bitu wyzwalającego.
+// ================================================================================================
+type AlarmDef struct {
+	Number       int
+	TriggerTag   string
+	TriggerBitNr int
+	Analog       bool
+	Texts        LangTexts
+	Infotexts    LangTexts
+
+	Class, Group string
+
+	// AckHMITag, AckPLCTag - tagi potwierdzenia z HMI i sterownika (puste - bez potwierdzenia)
+	AckHMITag   string
+	AckHMIBitNr int
+	AckPLCTag   string
+	AckPLCBitNr int
+
+	// Limit - granica alarmu analogowego (liczba lub nazwa taga), LimitMode - warunek (AnalogAbove, ...)
+	Limit             string
+	LimitMode         string
+	Hysteresis        float64
+	HysteresisPercent bool
+	HysteresisMode    string
+}
@@ -0,0 +1,328 @@
+package tagsgen
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// decodeFlexTagSymLine - rozdzielenie pól w linii
+// ================================================================================================
+func decodeFlexTagSymLine(s string, filename string) (sFieldSym string, sFieldPer string, sNr string, sFieldAddHI string, sFieldAddLO string, sFieldsTyp string, sFieldSize string, sFieldCom string, sFieldDataType string) {
+
+	// fmt.Println(s)
+
+	fields := strings.Split(s, "\t")
+
+	if strings.Contains(filename, ".csv") && len(fields[0]) > 0 {
+
+		if fields[0][0] != '#' {
+			// fmt.Println(fields)
+
+			var add string
+
+			if len(fields) > 0 {
+				sFieldSym = fields[0]
+				// fmt.Println(sFieldSym)
+			}
+			if len(fields) > 2 {
+				subFields := strings.Split(fields[2], " ")
+
+				if len(subFields) > 0 {
+
+					if subFields[0] == "DB" {
+						// ----------------------------------------------------
+						// Jeżeli to DB to tak
+						// ----------------------------------------------------
+
+						if len(subFields) > 1 {
+							sFieldPer = subFields[0] + subFields[1]
+							// fmt.Println(sFieldPer)
+						}
+						if len(subFields) > 1 {
+							sNr = subFields[1]
+							// fmt.Println(sNr)
+						}
+						if len(subFields) > 2 {
+							sFieldsTyp = subFields[2]
+							// fmt.Println(sFieldsTyp)
+						}
+						if len(subFields) > 3 {
+							add = subFields[3]
+							// fmt.Println(add)
+
+							addHILO := strings.Split(add, ".")
+
+							if len(addHILO) > 0 {
+								sFieldAddHI = addHILO[0]
+							}
+							if len(addHILO) > 1 {
+								sFieldAddLO = addHILO[1]
+							}
+
+						}
+
+					} else {
+
+						// ----------------------------------------------------
+						// Jeżeli nie DB to tak (I 10.6, IW 124, M 70.0, Q 483.4)
+						// ----------------------------------------------------
+
+						switch subFields[0] {
+						case "I", "IB", "IW", "ID", "M", "MB", "MW", "MD", "Q", "QB", "QW", "QD":
+							if len(subFields) > 1 {
+								sFieldPer = subFields[0]
+
+								addHILO := strings.Split(subFields[1], ".")
+
+								sFieldAddHI = addHILO[0]
+								if len(addHILO) > 1 {
+									sFieldAddLO = addHILO[1]
+								}
+							}
+						}
+
+					}
+				}
+			}
+			if len(fields) > 3 {
+				sFieldDataType = fields[3]
+			}
+			if len(fields) > 5 {
+				// Jeżeli pole bitowe to długość zero
+				if sFieldsTyp != "" {
+					sFieldSize = periphSize(sFieldsTyp)
+				} else {
+					sFieldSize = periphSize(sFieldPer)
+				}
+
+				// Stringi (kolumna E - długość) i tablice (kolumna F - liczba elementów) - rozmiar w bajtach
+				length, _ := strconv.Atoi(fields[4])
+				count, _ := strconv.Atoi(fields[5])
+				sFieldSize = arraySize(sFieldSize, sFieldDataType, length, count)
+
+				// fmt.Println(sFieldsTyp)
+				// fmt.Println(sFieldSize)
+			}
+			if len(fields) > 19 {
+				sFieldCom = fields[19]
+				// fmt.Println(sFieldCom)
+			}
+		}
+
+	}
+
+	return
+}
+
+// LoadFlexTags - wczytanie tagów HMI WinCC flexible (Tags.csv, UTF-16)
+// ================================================================================================
+func (p *Project) LoadFlexTags(r io.Reader) error {
+	return p.loadFlexTags(r, "Tags.csv")
+}
+
+// loadFlexTags - wczytanie tagów HMI WinCC flexible; linie, których nie da się przetworzyć, są pomijane
+// ================================================================================================
+func (p *Project) loadFlexTags(r io.Reader, name string) error {
+
+	lines, err := readLinesUTF16(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	for i, line := range lines {
+
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			p.parseErrorf(name, i+1, line, ErrMalformedLine, "")
+			continue
+		}
+
+		// Tagi wewnętrzne HMI nie mają połączenia ze sterownikiem
+		if fields[1] == "" {
+			continue
+		}
+
+		sSymbol, sPer, sNr, sAddHI, sAddLO, sType, sSize, sComment, sDataType := decodeFlexTagSymLine(line, ".csv")
+		var newSymbol = Symbol{sSymbol, sPer, sNr, sAddHI, sAddLO, sType, sSize, sComment, sDataType}
+
+		if sPer == "" {
+			p.parseErrorf(name, i+1, "", ErrUnsupportedAddress, "%s %q", sSymbol, fields[2])
+			continue
+		}
+		if err := checkAddress(newSymbol); err != nil {
+			p.parseErrorf(name, i+1, "", err, "%s %q", sSymbol, fields[2])
+			continue
+		}
+
+		p.Symbols = append(p.Symbols, newSymbol)
+	}
+
+	return nil
+}
+
+// Kolumny eksportu alarmów WinCC flexible (Alarms.csv)
+// ================================================================================================
+const (
+	flexColAlarmType  = "Alarm type"
+	flexColNumber     = "Alarm number"
+	flexColTriggerTag = "Trigger tag"
+	flexColTriggerBit = "Trigger bit number"
+	flexColClass      = "Alarm class"
+	flexColGroup      = "Alarm group"
+	flexColAckHMITag  = "Acknowledgment HMI tag"
+	flexColAckHMIBit  = "Acknowledgment HMI tag bit number"
+	flexColAckPLCTag  = "Acknowledgment PLC tag"
+	flexColAckPLCBit  = "Acknowledgment PLC tag bit number"
+	flexColLimit      = "Limit"
+	flexColLimitMode  = "Limit mode"
+	flexColTrigMode   = "Trigger mode"
+	flexColHyst       = "Hysteresis"
+	flexColHystPct    = "Hysteresis in %"
+	flexColHystMode   = "Hysteresis mode"
+	flexColText       = "Text"
+	flexColInfoText   = "Infotext"
+)
+
+// flexAlarmHeader - kolumny alarmów, gdy plik nie ma linii nagłówka ("//Alarm type	Alarm number...")
+// ================================================================================================
+var flexAlarmHeader = strings.Split("Alarm type\tAlarm number\tAlarm class\tTrigger tag\tTrigger bit number\t"+
+	"Acknowledgment HMI tag\tAcknowledgment HMI tag bit number\tAcknowledgment PLC tag\tAcknowledgment PLC tag bit number\t"+
+	"Alarm group\tReported\tText[de-DE]\tText[en-US]\tText[es-ES]\tText[fr-FR]\tText[hu-HU]\tText[it-IT]\tText[pl-PL]\t"+
+	"Field info[01]\tField info[02]\tInfotext[de-DE]\tInfotext[en-US]\tInfotext[es-ES]\tInfotext[fr-FR]\tInfotext[hu-HU]\tInfotext[it-IT]\tInfotext[pl-PL]", "\t")
+
+// flexTextColumn - kolumna tekstu w języku, np. "Text[de-DE]", "Infotext[pl-PL]"
+// ================================================================================================
+var flexTextColumn = regexp.MustCompile(`^(.+)\[([a-z]{2}-[A-Z]{2})\]$`)
+
+// flexCell - wartość kolumny bez cudzysłowów; pusta, gdy nie ma kolumny
+// ================================================================================================
+func flexCell(fields []string, cols map[string]int, name string) string {
+	i, ok := cols[name]
+	if !ok || i >= len(fields) {
+		return ""
+	}
+	return strings.ReplaceAll(fields[i], "\"", "")
+}
+
+// flexTexts - teksty ze wszystkich kolumn językowych ("de-DE=tekst"), również puste
+// ================================================================================================
+func flexTexts(header []string, fields []string, name string) (texts LangTexts) {
+	for i, h := range header {
+		m := flexTextColumn.FindStringSubmatch(h)
+		if m == nil || m[1] != name {
+			continue
+		}
+		if texts == nil {
+			texts = make(LangTexts)
+		}
+		texts[m[2]] = ""
+		if i < len(fields) {
+			text := strings.ReplaceAll(fields[i], "\"", "")
+			texts[m[2]] = strings.TrimPrefix(text, m[2]+"=")
+		}
+	}
+	return
+}
+
+// LoadFlexAlarms - wczytanie alarmów WinCC flexible (Alarms.csv, UTF-16)
+// Kolumny wg linii nagłówka "//Alarm type	Alarm number	..." (bez nagłówka - układ WinCC
+// flexible 2008 z 7 językami).
+// ================================================================================================
+func (p *Project) LoadFlexAlarms(r io.Reader, srcFile string) error {
+
+	lines, err := readLinesUTF16(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", srcFile, err)
+	}
+
+	p.Alarms.SourceFilename = srcFile
+	if len(lines) > 0 {
+		p.Alarms.SourceInfo = lines[0]
+	}
+
+	header := flexAlarmHeader
+	cols := xlsxColumns(header)
+
+	// Loop through lines & turn into object
+	for i, alarm := range lines {
+
+		if strings.HasPrefix(alarm, "//"+flexColAlarmType+"\t") {
+			header = strings.Split(strings.TrimPrefix(alarm, "//"), "\t")
+			cols = xlsxColumns(header)
+			continue
+		}
+
+		// komentarze tylko na początku linii - "#" i "//" mogą wystąpić w tekstach alarmów
+		trimmed := strings.TrimSpace(alarm)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "//") {
+			fields := strings.Split(alarm, "\t")
+			if len(fields) <= cols[flexColTriggerTag] {
+				p.parseErrorf(srcFile, i+1, alarm, ErrMalformedLine, "")
+				continue
+			}
+
+			alarmNumber, err := strconv.Atoi(flexCell(fields, cols, flexColNumber))
+			if err != nil {
+				p.parseErrorf(srcFile, i+1, "", ErrInvalidNumber, "%s", fields[cols[flexColNumber]])
+				continue
+			}
+			triggerTag := flexCell(fields, cols, flexColTriggerTag)
+			analog := flexCell(fields, cols, flexColAlarmType) == "A"
+
+			// bit wyzwalający (wymagany dla alarmów bitowych) i bity potwierdzenia
+			var bitNrs [3]int
+			valid := true
+			for j, col := range []string{flexColTriggerBit, flexColAckHMIBit, flexColAckPLCBit} {
+				cell := flexCell(fields, cols, col)
+				if bitNrs[j], err = parseBitNr(cell, j == 0 && !analog); err != nil {
+					p.parseErrorf(srcFile, i+1, "", err, "alarm %d %s %q", alarmNumber, col, cell)
+					valid = false
+					break
+				}
+			}
+			if !valid {
+				continue
+			}
+			triggerBitNr, ackHMIBitNr, ackPLCBitNr := bitNrs[0], bitNrs[1], bitNrs[2]
+
+			def := AlarmDef{
+				Number:       alarmNumber,
+				TriggerTag:   triggerTag,
+				TriggerBitNr: triggerBitNr,
+				Texts:        flexTexts(header, fields, flexColText),
+				Infotexts:    flexTexts(header, fields, flexColInfoText),
+				Class:        flexCell(fields, cols, flexColClass),
+				Group:        flexCell(fields, cols, flexColGroup),
+				AckHMITag:    flexCell(fields, cols, flexColAckHMITag),
+				AckHMIBitNr:  ackHMIBitNr,
+				AckPLCTag:    flexCell(fields, cols, flexColAckPLCTag),
+				AckPLCBitNr:  ackPLCBitNr,
+			}
+
+			// alarm analogowy - granica (wartość lub tag), warunek i histereza
+			if analog {
+				mode := flexCell(fields, cols, flexColLimitMode)
+				if _, ok := cols[flexColLimitMode]; !ok {
+					mode = flexCell(fields, cols, flexColTrigMode)
+				}
+				err := analogAlarmDef(&def, flexCell(fields, cols, flexColLimit), mode, flexCell(fields, cols, flexColHyst),
+					flexCell(fields, cols, flexColHystPct), flexCell(fields, cols, flexColHystMode))
+				if err != nil {
+					p.parseErrorf(srcFile, i+1, "", err, "alarm %d", alarmNumber)
+					continue
+				}
+			}
+
+			p.AlarmDefs = append(p.AlarmDefs, def)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package tagsgen
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+// utf16File - tekst pliku eksportu WinCC flexible (UTF-16 LE z BOM)
+// ================================================================================================
+func utf16File(t *testing.T, lines ...string) *bytes.Reader {
+	t.Helper()
+	data, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String(strings.Join(lines, "\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader([]byte(data))
+}
+
+func TestLoadFlexAlarms(t *testing.T) {
+
+	header := "//Alarm type\tAlarm number\tTrigger tag\tTrigger bit number\tAcknowledgment HMI tag\tAcknowledgment HMI tag bit number\tText[de-DE]"
+	p := NewProject()
+	err := p.LoadFlexAlarms(utf16File(t,
+		"// WinCC flexible 2008",
+		"# comment",
+		header,
+		"",
+		`"D"	"1"	"Status"	"3"	""	""	"de-DE=Station #3 gestört"`,
+		`"D"	"2"	"Status"	"4"	"Ack"	"1"	"de-DE=Hilfe: http://example.com//stoerung"`,
+		`"D"	"3"	"Status"	"x"	""	""	"de-DE=Ungültiges Bit"`,
+		`"D"	"4"	"Status"	""	""	""	"de-DE=Ohne Bit"`,
+		`"D"	"5"	"Status"	"1"	"Ack"	"-1"	"de-DE=Ungültiges Quittierbit"`,
+		`"A"	"6"	"Level"	""	""	""	"de-DE=Analog"	`,
+	), "Alarms.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var numbers []int
+	for _, def := range p.AlarmDefs {
+		numbers = append(numbers, def.Number)
+	}
+	if len(numbers) != 2 || numbers[0] != 1 || numbers[1] != 2 {
+		t.Fatalf("alarms = %v", numbers)
+	}
+	if d := p.AlarmDefs[0]; d.TriggerBitNr != 3 || d.Texts["de-DE"] != "Station #3 gestört" {
+		t.Errorf("alarm 1 = %+v", d)
+	}
+	if d := p.AlarmDefs[1]; d.AckHMITag != "Ack" || d.AckHMIBitNr != 1 {
+		t.Errorf("alarm 2 = %+v", d)
+	}
+
+	// alarmy 3, 4, 5 - numery bitów; alarm 6 - analogowy bez granicy
+	if len(p.ParseErrors) != 4 {
+		t.Fatalf("parse errors = %v", p.ParseErrors)
+	}
+	for _, e := range p.ParseErrors[:3] {
+		if !errors.Is(e, ErrInvalidBitNumber) {
+			t.Errorf("%v: want %v", e, ErrInvalidBitNumber)
+		}
+	}
+	if e := p.ParseErrors[0]; e.File != "Alarms.csv" || e.Line != 7 {
+		t.Errorf("error position %s:%d", e.File, e.Line)
+	}
+}
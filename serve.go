@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dkt64/tagsgenerator/pkg/alarms"
+	"github.com/dkt64/tagsgenerator/pkg/decoder"
+	"github.com/dkt64/tagsgenerator/pkg/ingest"
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
+)
+
+// liveValue - ostatnia wartość symbolu z IoT Gateway
+// ================================================================================================
+type liveValue struct {
+	Value     interface{}
+	Quality   bool
+	Timestamp time.Time
+}
+
+// tagState - opis symbolu z tags.json z ostatnią wartością
+// ================================================================================================
+type tagState struct {
+	tagsgen.CsvTag
+	Value     interface{} `json:",omitempty"`
+	Quality   bool
+	Timestamp *time.Time `json:",omitempty"`
+}
+
+// server - model projektu (tags.json, alarms.json) z wartościami na żywo przez HTTP
+// ================================================================================================
+type server struct {
+	dec     *decoder.Decoder
+	engine  *alarms.Engine
+	history *alarms.History
+
+	mu     sync.Mutex
+	values map[string]liveValue // symbol -> wartość
+}
+
+// newServer - serwer dla dekodera; alarmy tylko, gdy dekoder zawiera alarms.json
+// ================================================================================================
+func newServer(dec *decoder.Decoder, lang string) *server {
+
+	s := &server{dec: dec, values: make(map[string]liveValue)}
+	if len(dec.Alarms.Alarms) > 0 {
+		s.engine = alarms.NewEngine(dec.Alarms, lang)
+	}
+	return s
+}
+
+// writeJSONResponse - odpowiedź json
+// ================================================================================================
+func writeJSONResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+// writeError - odpowiedź z błędem
+// ================================================================================================
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSONResponse(w, status, map[string]string{"error": err.Error()})
+}
+
+// Write - nowe wartości z komunikatu IoT Gateway (odbiornik ingest.Sink)
+// ================================================================================================
+func (s *server) Write(b *ingest.Batch) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range b.Symbols {
+		if !v.Quality {
+			// zła jakość - ostatnia wartość zostaje
+			lv := s.values[v.Symbol]
+			lv.Quality = false
+			lv.Timestamp = v.Time
+			s.values[v.Symbol] = lv
+			continue
+		}
+		s.values[v.Symbol] = liveValue{Value: v.Value, Quality: true, Timestamp: v.Time}
+	}
+
+	if s.engine != nil {
+		events := s.engine.UpdateValues(s.dec, b.Items)
+		if s.history != nil && len(events) > 0 {
+			return s.history.Record(events...)
+		}
+	}
+	return nil
+}
+
+// tagState - symbol z ostatnią wartością
+// ================================================================================================
+func (s *server) tagState(t tagsgen.CsvTag) tagState {
+	st := tagState{CsvTag: t}
+	if lv, ok := s.values[t.SymbolName]; ok {
+		st.Value, st.Quality = lv.Value, lv.Quality
+		stamp := lv.Timestamp
+		st.Timestamp = &stamp
+	}
+	return st
+}
+
+// handleTags - GET /tags, GET /tags/{symbol}
+// ================================================================================================
+func (s *server) handleTags(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/tags"), "/")
+	if name == "" {
+		list := make([]tagState, 0, len(s.dec.Tags.Tags))
+		for _, t := range s.dec.Tags.Tags {
+			list = append(list, s.tagState(t))
+		}
+		writeJSONResponse(w, http.StatusOK, list)
+		return
+	}
+
+	t, ok := s.dec.Symbol(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", decoder.ErrUnknownSymbol, name))
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, s.tagState(t))
+}
+
+// handleAlarms - GET /alarms, GET /alarms/active
+// ================================================================================================
+func (s *server) handleAlarms(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if s.engine == nil {
+		writeJSONResponse(w, http.StatusOK, []alarms.Alarm{})
+		return
+	}
+
+	var list []alarms.Alarm
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/alarms":
+		list = s.engine.Alarms()
+	case "/alarms/active":
+		list = s.engine.Active()
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	// filtrowanie wg klasy i grupy: /alarms?class=Errors&group=1
+	class, group := r.URL.Query().Get("class"), r.URL.Query().Get("group")
+	filtered := []alarms.Alarm{}
+	for _, a := range list {
+		if (class == "" || strings.EqualFold(a.Class, class)) && (group == "" || a.Group == group) {
+			filtered = append(filtered, a)
+		}
+	}
+	writeJSONResponse(w, http.StatusOK, filtered)
+}
+
+// runServe - polecenie serve: tagi i alarmy z wartościami na żywo przez HTTP
+// tagsgenerator serve [-addr :8080] [-tags tags.json] [-alarms alarms.json] [-ingest /iotgateway]
+// ================================================================================================
+func runServe(args []string) {
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "HTTP listen address")
+	tagsFilename := fs.String("tags", "tags.json", "Tags description file (input)")
+	alarmsFilename := fs.String("alarms", "alarms.json", "Alarms description file (input), empty - no alarms")
+	lang := fs.String("lang", "", "Language of alarm texts, e.g. de-DE (default: first text)")
+	ingestPath := fs.String("ingest", "/iotgateway", "URL path for POSTs of the IoT Gateway REST Client agent")
+	historyFilename := fs.String("history", "", "Record alarm events to this alarm history database, e.g. alarms.db")
+	printValues := fs.Bool("print", false, "Print every received symbol value as a JSON line")
+	fs.Parse(args)
+
+	if *alarmsFilename != "" && !fileExists(*alarmsFilename) {
+		fmt.Fprintf(os.Stderr, "WARNING: %s not found, serving without alarms\n", *alarmsFilename)
+		*alarmsFilename = ""
+	}
+
+	dec, err := decoder.Load(*tagsFilename, *alarmsFilename)
+	if err != nil {
+		fail(exitError, err)
+	}
+
+	s := newServer(dec, *lang)
+	if *historyFilename != "" {
+		if s.engine == nil {
+			fail(exitUsage, errors.New("-history requires alarms"))
+		}
+		if s.history, err = alarms.OpenHistory(*historyFilename); err != nil {
+			fail(exitError, err)
+		}
+		defer s.history.Close()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tags", s.handleTags)
+	mux.HandleFunc("/tags/", s.handleTags)
+	mux.HandleFunc("/alarms", s.handleAlarms)
+	mux.HandleFunc("/alarms/", s.handleAlarms)
+	sinks := ingest.Sinks{s}
+	if *printValues {
+		sinks = append(sinks, ingest.NewJSONSink(os.Stdout))
+	}
+	mux.Handle(*ingestPath, ingest.NewHandler(dec, sinks))
+
+	fmt.Printf("Serving %d symbols, %d alarms on %s, IoT Gateway REST Client POST path %s ...\n", len(dec.Tags.Tags), len(dec.Alarms.Alarms), *addr, *ingestPath)
+
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		fail(exitError, err)
+	}
+}
@@ -2,193 +2,189 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
-	"time"
 
-	"golang.org/x/text/encoding/charmap"
-	"golang.org/x/text/encoding/unicode"
-	"golang.org/x/text/transform"
+	"github.com/dkt64/tagsgenerator/pkg/tagsgen"
 )
 
-// CsvAlarm - typ przechowujący dane o alarmach
+// Kody wyjścia programu
 // ================================================================================================
-type CsvAlarm struct {
-	Number  int
-	TagName string
-	Index   int
-	BitNr   int
-	Texts   []string
-}
+const (
+	exitError  = 1 // błąd odczytu / zapisu plików lub brak symboli
+	exitUsage  = 2 // niepoprawne parametry
+	exitStrict = 3 // pominięte linie w trybie -strict
 
-// Alarms - typ przechowujący dane o alarmach
-// ================================================================================================
-type Alarms struct {
-	ConnectionName string
-	Timestamp      int64
-	SourceFilename string
-	SourceInfo     string
-	Alarms         []CsvAlarm
-}
-
-var alarms Alarms
+	exitUnresolved = 4 // alarmy bez taga w trybie -fail-unresolved
+)
 
-// CsvTag - typ przechowujący dane o alarmach
+// fail - komunikat błędu i zakończenie programu z kodem wyjścia
 // ================================================================================================
-type CsvTag struct {
-	SymbolName      string
-	SymbolPeriph    string
-	SymbolAddressHi string
-	SymbolAddressLo string
-	Comment         string
-	TagName         string
-	Index           int
-	BitNr           int
-	Size            int
+func fail(code int, err error) {
+	fmt.Fprintln(os.Stderr, "ERROR:", err)
+	os.Exit(code)
 }
 
-// Tags - typ przechowujący dane o alarmach
+// parseErrorKinds - rodzaje błędów w podsumowaniu pominiętych linii
 // ================================================================================================
-type Tags struct {
-	ConnectionName string
-	Timestamp      int64
-	SourceFilename string
-	SourceInfo     string
-	Tags           []CsvTag
+var parseErrorKinds = []error{
+	tagsgen.ErrMalformedLine,
+	tagsgen.ErrInvalidAddress,
+	tagsgen.ErrUnsupportedAddress,
+	tagsgen.ErrNoAbsoluteAddress,
+	tagsgen.ErrInvalidNumber,
+	tagsgen.ErrInvalidBitNumber,
+	tagsgen.ErrInvalidAnalogAlarm,
 }
 
-var tags Tags
-
-// KepTag - tagi - odwzorowanie linii taga w wartości
+// maxParseErrorDetails - liczba wypisywanych szczegółowo pominiętych linii na plik
 // ================================================================================================
-type KepTag struct {
-	Type          string
-	StartingIndex int
-	Size          int
-}
-
-var kepTags []KepTag
+const maxParseErrorDetails = 10
 
-// Symbol - typ przechowujący dane o symbolu
+// printParseErrors - podsumowanie pominiętych linii plików wejściowych
 // ================================================================================================
-type Symbol struct {
-	sSymbol, sPer, sNr, sAddHI, sAddLO, sType, sSize, sComment string
-}
-
-var symbols []Symbol
+func printParseErrors(w io.Writer, parseErrors []*tagsgen.ParseError) {
 
-// DBBlock - typ przechowujący dane o bloku DB
-// ================================================================================================
-type DBBlock struct {
-	nr  int
-	tab [65536]byte
-}
-
-var dbBlocks []DBBlock
-
-// ErrCheck - obsługa błedów
-// ================================================================================================
-func ErrCheck(errNr error) bool {
-	if errNr != nil {
-		fmt.Println(errNr)
-		return false
+	if len(parseErrors) == 0 {
+		return
 	}
-	return true
-}
 
-// ErrCheck2 - obsługa błedów
-// ================================================================================================
-func ErrCheck2(errNr error) bool {
-	if errNr != nil {
-		return false
+	byFile := make(map[string][]*tagsgen.ParseError)
+	var files []string
+	for _, e := range parseErrors {
+		if _, ok := byFile[e.File]; !ok {
+			files = append(files, e.File)
+		}
+		byFile[e.File] = append(byFile[e.File], e)
+	}
+	sort.Strings(files)
+
+	fmt.Fprintf(w, "Skipped %d lines of input files:\n", len(parseErrors))
+	for _, file := range files {
+		fileErrors := byFile[file]
+
+		fmt.Fprintf(w, "  %s: %d lines\n", file, len(fileErrors))
+		for _, kind := range parseErrorKinds {
+			n := 0
+			for _, e := range fileErrors {
+				if errors.Is(e, kind) {
+					n++
+				}
+			}
+			if n > 0 {
+				fmt.Fprintf(w, "    %s: %d\n", kind, n)
+			}
+		}
+		for i, e := range fileErrors {
+			if i == maxParseErrorDetails {
+				fmt.Fprintf(w, "    ... %d more\n", len(fileErrors)-maxParseErrorDetails)
+				break
+			}
+			fmt.Fprintln(w, "    "+e.Error())
+		}
 	}
-	return true
 }
 
-// DecodeWindows1250 - dekodowanie ASCII
+// unresolvedReasons - przyczyny w podsumowaniu pominiętych alarmów
 // ================================================================================================
-func DecodeWindows1250(enc string) string {
-	dec := charmap.Windows1250.NewDecoder()
-	out, _ := dec.String(enc)
-	return string(out)
+var unresolvedReasons = []error{
+	tagsgen.ErrUnknownTag,
+	tagsgen.ErrNotInBlock,
+	tagsgen.ErrBitOutsideBlock,
+	tagsgen.ErrDuplicateNumber,
+	tagsgen.ErrUnresolvedAck,
 }
 
-// readLines reads a whole file into memory and returns a slice of its lines.
+// printUnresolvedAlarms - raport alarmów pominiętych w alarms.json
 // ================================================================================================
-func readLines(path string) ([]string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+func printUnresolvedAlarms(unresolved []tagsgen.UnresolvedAlarm) {
+
+	if len(unresolved) == 0 {
+		return
 	}
-	defer file.Close()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	fmt.Printf("Unresolved %d alarms:\n", len(unresolved))
+	for _, reason := range unresolvedReasons {
+		n := 0
+		for _, u := range unresolved {
+			if u.Reason == reason.Error() {
+				n++
+			}
+		}
+		if n > 0 {
+			fmt.Printf("  %s: %d\n", reason, n)
+		}
+	}
+	for _, u := range unresolved {
+		fmt.Printf("  %6d  %s: %s\n", u.Number, u.TriggerTag, u.Details)
 	}
-	return lines, scanner.Err()
 }
 
-// ReadFileUTF16 - Similar to ioutil.ReadFile() but decodes UTF-16.  Useful when
-// reading data from MS-Windows systems that generate UTF-16BE files,
-// but will do the right thing if other BOMs are found.
+// printDuplicateSymbols - symbole bez własnego taga, bo tag o tej nazwie ma już inny symbol
 // ================================================================================================
-func ReadFileUTF16(filename string) ([]byte, error) {
-
-	// Read the file into a []byte:
-	raw, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, err
+func printDuplicateSymbols(duplicates []tagsgen.Symbol) {
+	for _, sym := range duplicates {
+		fmt.Fprintf(os.Stderr, "WARNING: duplicate symbol name %s (%s %s), no tag generated\n", sym.Name, sym.Periph, sym.AddressHi)
 	}
+}
 
-	// Make an tranformer that converts MS-Win default to UTF8:
-	win16be := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
-	// Make a transformer that is like win16be, but abides by BOM:
-	utf16bom := unicode.BOMOverride(win16be.NewDecoder())
+// printBlockReport - raport liczby zapytań i przesyłanych bajtów: zachłannie / optymalnie
+// ================================================================================================
+func printBlockReport(report []tagsgen.BlockStats) {
 
-	// Make a Reader that uses utf16bom:
-	unicodeReader := transform.NewReader(bytes.NewReader(raw), utf16bom)
+	var greedy, optimized tagsgen.BlockCost
 
-	// decode and print:
-	decoded, err := ioutil.ReadAll(unicodeReader)
-	return decoded, err
+	fmt.Println("Block optimization (requests / bytes, greedy -> optimized):")
+	for _, s := range report {
+		fmt.Printf("  %-6s %5d / %6d -> %5d / %6d\n", s.Name, s.Greedy.Requests, s.Greedy.Bytes, s.Optimized.Requests, s.Optimized.Bytes)
+
+		greedy.Requests += s.Greedy.Requests
+		greedy.Bytes += s.Greedy.Bytes
+		optimized.Requests += s.Optimized.Requests
+		optimized.Bytes += s.Optimized.Bytes
+	}
+	fmt.Printf("  %-6s %5d / %6d -> %5d / %6d\n", "Total", greedy.Requests, greedy.Bytes, optimized.Requests, optimized.Bytes)
 }
 
-// readLinesUTF16 - reads a whole file into memory and returns a slice of its lines.
+// printBlockChanges - raport zmian bloków względem poprzedniej generacji
 // ================================================================================================
-func readLinesUTF16(path string) ([]string, error) {
-	myFile, err := ReadFileUTF16(path)
-	if err != nil {
-		return nil, err
-	}
-	// fmt.Println(string(myFile))
+func printBlockChanges(changes []tagsgen.BlockChange) {
 
-	var lines []string
-	bytesReader := bytes.NewReader(myFile)
-	scanner := bufio.NewReader(bytesReader)
-	for {
-		line, _, err := scanner.ReadLine()
+	added := 0
+	var changed []tagsgen.BlockChange
+	for _, c := range changes {
+		if c.Change == tagsgen.BlockAdded {
+			added++
+		} else {
+			changed = append(changed, c)
+		}
+	}
 
-		if err == nil {
-			// fmt.Println(string(line))
-			lines = append(lines, string(line))
+	fmt.Printf("Baseline: %d new blocks, %d existing tags changed\n", added, len(changed))
+	for _, c := range changed {
+		if c.Change == tagsgen.BlockResized {
+			fmt.Printf("  %s: %s [%d] -> [%d]\n", c.Name, c.Change, c.OldSize, c.NewSize)
 		} else {
-			// fmt.Println("ERROR")
-			break
+			fmt.Printf("  %s: %s\n", c.Name, c.Change)
 		}
 	}
-	if err == errors.New("EOF") {
-		return lines, nil
+}
+
+// writeJSON - zapis opisu tagów / alarmów do pliku json
+// ================================================================================================
+func writeJSON(v interface{}, path string) error {
+	file, err := json.MarshalIndent(v, "", " ")
+	if err != nil {
+		return err
 	}
-	return lines, err
+	return ioutil.WriteFile(path, file, 0666)
 }
 
 // writeLines writes the lines to the given file.
@@ -207,760 +203,338 @@ func writeLines(lines []string, path string) error {
 	return w.Flush()
 }
 
-// parseTIAAddress - rozdzielenie typu i adresu
+// fileExists - sprawdzenie czy plik istnieje
 // ================================================================================================
-func parseAddress(s string) (letters, numbers string) {
-	var l, n []rune
-	for _, r := range s {
-		switch {
-		case r >= 'A' && r <= 'Z':
-			l = append(l, r)
-		case r >= 'a' && r <= 'z':
-			l = append(l, r)
-		case r >= '0' && r <= '9':
-			n = append(n, r)
-		}
+func fileExists(filename string) bool {
+
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return false
 	}
-	return string(l), string(n)
+
+	return true
 }
 
-// decodeS7PLCSymLine - rozdzielenie pól w linii
+// main - program entry point
 // ================================================================================================
-func decodeS7PLCSymLine(s string, filename string) (sFieldSym string, sFieldPer string, sFieldNr string, sFieldAddHI string, sFieldAddLO string, sFieldsTyp string, sFieldSize string, sFieldCom string) {
-
-	if strings.Contains(filename, ".asc") {
-
-		startingIndex := strings.Index(s, ",") + 1
-
-		s1 := s[startingIndex:len(s)]
-
-		sFieldSym = s1[0:24]
-		sFieldSym = strings.TrimSpace(sFieldSym)
-
-		lineRest := s1[24:len(s1)]
-		fields := strings.Fields(lineRest)
-
-		if len(fields) > 0 {
-			sFieldPer = fields[0]
-		}
-		var add string
-		if len(fields) > 1 {
-			add = fields[1]
-		}
-		if len(fields) > 2 {
-			sFieldsTyp = fields[2]
-		}
-
-		if len(fields) > 3 {
-			for i := 3; i < len(fields); i++ {
-				sFieldCom = sFieldCom + fields[i] + " "
-			}
-			sFieldCom = sFieldCom[0 : len(sFieldCom)-1]
-			sFieldCom = strings.TrimRight(sFieldCom, " ")
-		}
-
-		addHILO := strings.Split(add, ".")
-
-		if len(addHILO) > 0 {
-			sFieldAddHI = addHILO[0]
-		}
-
-		if len(addHILO) > 1 {
-			sFieldAddLO = addHILO[1]
-		}
-
-		if sFieldPer == "I" {
-			sFieldSize = "0"
-		}
-		if sFieldPer == "IB" {
-			sFieldSize = "1"
-		}
-		if sFieldPer == "IW" {
-			sFieldSize = "2"
-		}
-		if sFieldPer == "ID" {
-			sFieldSize = "4"
-		}
-
-		if sFieldPer == "M" {
-			sFieldSize = "0"
-		}
-		if sFieldPer == "MB" {
-			sFieldSize = "1"
-		}
-		if sFieldPer == "MW" {
-			sFieldSize = "2"
-		}
-		if sFieldPer == "MD" {
-			sFieldSize = "4"
-		}
+func main() {
 
-		if sFieldPer == "Q" {
-			sFieldSize = "0"
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "listen":
+			runListen(os.Args[2:])
+			return
+		case "alarms":
+			runAlarms(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "deploy":
+			runDeploy(os.Args[2:])
+			return
 		}
-		if sFieldPer == "QB" {
-			sFieldSize = "1"
-		}
-		if sFieldPer == "QW" {
-			sFieldSize = "2"
-		}
-		if sFieldPer == "QD" {
-			sFieldSize = "4"
-		}
-
 	}
-	if strings.Contains(filename, ".sdf") {
 
-		fields := strings.Split(s, ",")
+	fmt.Println("=============================================================================================")
+	fmt.Println("==                         Siemens PLC Tags generator / DTP                                ==")
+	fmt.Println("==    Generator of Tags in form of csv configuration files for KepServerEX6 + IoTGateway   ==")
+	fmt.Println("=============================================================================================")
+	fmt.Println()
 
-		if len(fields) > 1 {
-			var add string
-			fullAdd := strings.ReplaceAll(strings.ReplaceAll(fields[1], "\"", ""), "%", "")
-			addHILO := strings.Split(fullAdd, ".")
+	defaults := tagsgen.DefaultOptions()
 
-			sFieldPer, add = parseAddress(addHILO[0])
+	hmiTagsFilename := flag.String("t", "", "WinCCflexible (Tags.csv) or TIA Portal (HMITags.xlsx) HMI Tags table filename (input)")
+	hmiAlarmsFilename := flag.String("a", "", "WinCCflexible (Alarms.csv) or TIA Portal (HMIAlarms.xlsx) alarms table filename (input)")
+	symFilename := flag.String("s", "", "Step7 (Symbols.asc) or TIA Portal (PLCTags.sdf) symbol table filename (input)")
+	plcFilename := flag.String("p", "plc.csv", "PLC Tags filename (output)")
+	iotFilename := flag.String("i", "iot.csv", "IoT Gateway Tags filename (output)")
+	connectionName := flag.String("c", defaults.ConnectionName, "Connection description")
+	blockSize := flag.Int("b", defaults.BlockSize, "Block size in [bytes]")
+	pduSize := flag.Int("pdu", 0, "Negotiated PLC PDU size in [bytes] (240, 480, 960), blocks are limited to the read payload (222, 462, 942); without -b blocks use the whole payload")
+	gapTolerance := flag.Int("gap", 0, "Merge blocks separated by up to this many unused bytes")
+	optimize := flag.Bool("optimize", false, "Partition blocks with minimal cost of S7 read requests instead of the greedy scan")
+	requestCost := flag.Float64("request-cost", defaults.RequestCost, "Cost of one S7 read request for -optimize (in bytes)")
+	byteCost := flag.Float64("byte-cost", defaults.ByteCost, "Cost of one transferred byte for -optimize")
+	pollFreq := flag.Int("f", defaults.ScanRate, "Frequency of polling in [ms]")
+	textListsFilename := flag.String("textlists", "", "WinCCflexible text lists (Textlists.csv) filename (input)")
+	textListLinksFilename := flag.String("textlist-links", "", "Links of HMI tags to text lists, lines \"<tag pattern> = <text list>\" (input), default: by name")
+	languages := flag.String("languages", "", "Comma separated languages of alarm and text list texts, e.g. de-DE,pl-PL (default: all languages of the export)")
+	fallbackLanguage := flag.String("fallback-lang", "", "Language of texts used for empty translations, e.g. de-DE")
+	baselineFilename := flag.String("baseline", "", "Previous plc.csv or tags.json - keep its block boundaries and names stable (input)")
+	strict := flag.Bool("strict", false, "Abort without writing any file when a line of an input file cannot be parsed")
+	unresolvedFilename := flag.String("unresolved-file", "unresolved_alarms.json", "Report of alarms whose trigger tag cannot be found in the generated tags (output)")
+	failUnresolved := flag.Bool("fail-unresolved", false, "Abort without writing any file except the unresolved alarms report when an alarm cannot be resolved")
+	agentType := flag.String("agent", "", "Generate an IoT Gateway agent definition (Kepware Configuration API JSON): "+tagsgen.AgentMQTT+", "+tagsgen.AgentRESTClient+" or "+tagsgen.AgentRESTServer)
+	agentFilename := flag.String("agent-file", "iot_agent.json", "IoT Gateway agent definition filename (output)")
+	agentName := flag.String("agent-name", defaults.Agent.Name, "IoT Gateway agent name")
+	agentURL := flag.String("agent-url", "", "MQTT broker URL (default tcp://localhost:1883) or REST Client endpoint URL (default http://localhost:8080/iotgateway)")
+	agentTopic := flag.String("agent-topic", defaults.Agent.Topic, "MQTT topic of the IoT Gateway agent")
+	agentRate := flag.Int("agent-rate", defaults.Agent.Rate, "Publish rate of the IoT Gateway agent in [ms]")
+	agentOnChange := flag.Bool("agent-on-change", false, "IoT Gateway agent publishes only changed values")
+	agentPort := flag.Int("agent-port", defaults.Agent.Port, "Port of the IoT Gateway REST Server agent")
+	deviceIP := flag.String("device-ip", "", "Generate the Kepware channel and device definition (Configuration API JSON) for the PLC with this IP address")
+	deviceModel := flag.String("device-model", defaults.Device.Model, "PLC model: "+strings.Join(tagsgen.DeviceModels(), ", "))
+	deviceRack := flag.Int("device-rack", defaults.Device.Rack, "PLC CPU rack")
+	deviceSlot := flag.Int("device-slot", defaults.Device.Slot, "PLC CPU slot (default: 2 for S7-300, 3 for S7-400, 1 for S7-1200/1500)")
+	deviceLocalTSAP := flag.String("device-local-tsap", "", "Local TSAP of the PLC connection (4 hex digits, e.g. 4D57)")
+	deviceRemoteTSAP := flag.String("device-remote-tsap", "", "Remote TSAP of the PLC connection (4 hex digits, e.g. 4D57)")
+	channelFilename := flag.String("channel-file", "channel.json", "Kepware channel and device definition filename (output)")
+	genMode := flag.String("mode", defaults.Mode, "Generation mode: "+tagsgen.ModeBlocks+" (byte array blocks), "+tagsgen.ModeSymbols+" (one typed tag per symbol) or "+tagsgen.ModeHybrid+" (blocks for bits, typed tags for analog values)")
+
+	configFilename := flag.String("config", "", "Project file (YAML, JSON or TOML) with inputs, outputs and options of one or more machines")
 
-			// fmt.Println(fullAdd, addHILO, sFieldPer, add)
+	flag.Parse()
 
-			if len(addHILO) > 0 {
-				sFieldAddHI = add
-			}
-			if len(addHILO) > 1 {
-				sFieldAddLO = addHILO[1]
+	if *configFilename != "" {
+		machines, err := loadConfig(*configFilename)
+		if err != nil {
+			fail(exitUsage, err)
+		}
+		for i := range machines {
+			m := &machines[i]
+			fmt.Printf("== %s (%d/%d) ==\n", m.Name, i+1, len(machines))
+			if code, err := generateMachine(m); err != nil {
+				fail(code, fmt.Errorf("%s: %w", m.Name, err))
 			}
+			fmt.Println()
 		}
-
+		return
 	}
 
-	return
-}
-
-// decodeFlexTagSymLine - rozdzielenie pól w linii
-// ================================================================================================
-func decodeFlexTagSymLine(s string, filename string) (sFieldSym string, sFieldPer string, sNr string, sFieldAddHI string, sFieldAddLO string, sFieldsTyp string, sFieldSize string, sFieldCom string) {
-
-	// fmt.Println(s)
-
-	fields := strings.Split(s, "\t")
-
-	if strings.Contains(filename, ".csv") && len(fields[0]) > 0 {
-
-		if fields[0][0] != '#' {
-			// fmt.Println(fields)
-
-			var add string
-
-			if len(fields) > 0 {
-				sFieldSym = fields[0]
-				// fmt.Println(sFieldSym)
-			}
-			if len(fields) > 2 {
-				subFields := strings.Split(fields[2], " ")
-
-				if len(subFields) > 0 {
-
-					if subFields[0] == "DB" {
-						// ----------------------------------------------------
-						// Jeżeli to DB to tak
-						// ----------------------------------------------------
-
-						if len(subFields) > 1 {
-							sFieldPer = subFields[0] + subFields[1]
-							// fmt.Println(sFieldPer)
-						}
-						if len(subFields) > 1 {
-							sNr = subFields[1]
-							// fmt.Println(sNr)
-						}
-						if len(subFields) > 2 {
-							sFieldsTyp = subFields[2]
-							// fmt.Println(sFieldsTyp)
-						}
-						if len(subFields) > 3 {
-							add = subFields[3]
-							// fmt.Println(add)
-
-							addHILO := strings.Split(add, ".")
-
-							if len(addHILO) > 0 {
-								sFieldAddHI = addHILO[0]
-							}
-							if len(addHILO) > 1 {
-								sFieldAddLO = addHILO[1]
-							}
-
-						}
-
-					} else {
-
-						// ----------------------------------------------------
-						// Jeżeli nie DB to tak
-						// ----------------------------------------------------
-
-						// if len(subFields) > 1 {
-						// 	sFieldPer = subFields[0] + subFields[1]
-						// 	// fmt.Println(sFieldPer)
-						// }
-
-					}
-				}
-			}
-			if len(fields) > 5 {
-				// Jeżeli pole bitowe to długość zero
-
-				sSize := fields[5]
-				size, _ := strconv.Atoi(sSize)
-
-				if size > 4 {
-					sFieldSize = fields[5]
-				} else {
-					if sFieldsTyp == "DBX" {
-						sFieldSize = "0"
-					}
-					if sFieldsTyp == "DBB" {
-						sFieldSize = "1"
-					}
-					if sFieldsTyp == "DBW" {
-						sFieldSize = "2"
-					}
-					if sFieldsTyp == "DBD" {
-						sFieldSize = "4"
-					}
-				}
-
-				// fmt.Println(sFieldsTyp)
-				// fmt.Println(sFieldSize)
-			}
-			if len(fields) > 19 {
-				sFieldCom = fields[19]
-				// fmt.Println(sFieldCom)
+	// z -pdu bez -b bloki mają rozmiar całego PDU
+	if *pduSize > 0 {
+		blockSet := false
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "b" {
+				blockSet = true
 			}
+		})
+		if !blockSet {
+			*blockSize = 0
 		}
+	}
 
+	m := defaultMachine()
+	m.Symbols, m.Tags, m.Alarms, m.Baseline = *symFilename, *hmiTagsFilename, *hmiAlarmsFilename, *baselineFilename
+	m.TextLists, m.TextListLinks = *textListsFilename, *textListLinksFilename
+	m.Languages, m.FallbackLanguage = splitList(*languages), *fallbackLanguage
+	m.PLC, m.IOT, m.AgentFile, m.ChannelFile = *plcFilename, *iotFilename, *agentFilename, *channelFilename
+	m.Strict = *strict
+	m.UnresolvedFile, m.FailUnresolved = *unresolvedFilename, *failUnresolved
+	m.Connection, m.BlockSize, m.ScanRate, m.Mode = *connectionName, *blockSize, *pollFreq, *genMode
+	m.PDU, m.Gap, m.Optimize, m.RequestCost, m.ByteCost = *pduSize, *gapTolerance, *optimize, *requestCost, *byteCost
+	m.Agent = agentConfig{
+		Type:     *agentType,
+		Name:     *agentName,
+		URL:      *agentURL,
+		Topic:    *agentTopic,
+		QoS:      m.Agent.QoS,
+		ClientID: m.Agent.ClientID,
+		Rate:     *agentRate,
+		OnChange: *agentOnChange,
+		Port:     *agentPort,
+	}
+	m.Device = deviceConfig{
+		Model:      *deviceModel,
+		IP:         *deviceIP,
+		Port:       m.Device.Port,
+		Rack:       *deviceRack,
+		Slot:       *deviceSlot,
+		LocalTSAP:  *deviceLocalTSAP,
+		RemoteTSAP: *deviceRemoteTSAP,
 	}
 
-	return
+	if code, err := generateMachine(&m); err != nil {
+		fail(code, err)
+	}
 }
 
-// prepPLCImageBlocks - wygenerowanie listy tagów w blokach na podstawie obrazu zajętości
-// plc = prepPLCImageBlocks(iImage, "IB", bSize, freq)
+// splitList - lista wartości oddzielonych przecinkami (pusta dla pustego tekstu)
 // ================================================================================================
-func prepPLCImageBlocks(image [65536]byte, name string, blockSize int, freq int) (outLines []string) {
-
-	var imagePtr1, imagePtr2 int
-	// var lastSize byte
-	for imagePtr1 = 0; imagePtr1 < 65536-blockSize; imagePtr1++ {
-		found := false
-		if image[imagePtr1] > 0 {
-			found = true
-			for imagePtr2 = 0; imagePtr2 < blockSize; {
-				if image[imagePtr1+imagePtr2] > 0 {
-					imagePtr2 += int(image[imagePtr1+imagePtr2])
-				} else {
-					break
-				}
-			}
-		}
-
-		if found {
-			var line string
-
-			var newTag KepTag
-
-			newTag.Type = name
-			newTag.StartingIndex = imagePtr1
-			newTag.Size = imagePtr2
-
-			kepTags = append(kepTags, newTag)
-
-			if !strings.Contains(name, "DB") {
-				line = fmt.Sprintf("\"tab%s_%d\",\"%s%d[%d]\",Byte Array,1,RO,%d,,,,,,,,,,\"\",", name, imagePtr1, name, imagePtr1, imagePtr2, freq)
-			} else {
-				line = fmt.Sprintf("\"tab%s_%d\",\"%s.DBB%d[%d]\",Byte Array,1,RO,%d,,,,,,,,,,\"\",", name, imagePtr1, name, imagePtr1, imagePtr2, freq)
-			}
-			outLines = append(outLines, line)
-			imagePtr1 += imagePtr2 - 1
+func splitList(s string) (list []string) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
 		}
 	}
-
 	return
 }
 
-// generateIOT - funkcja generująca plik iot
-// "tabIB0","IB0[8]",Byte Array,1,R,100,,,,,,,,,,"",
-// "SiemensTCPIP.LivePLC01.tabIB0",100,Byte Array,0.000000,0,0,1
+// findInput - plik wejściowy z katalogu maszyny, gdy nie został podany
 // ================================================================================================
-func generateIOT(plc []string, connectionName string, freq int) (iot []string) {
-	iot = append(iot, ";")
-	iot = append(iot, "; IOTItem")
-	iot = append(iot, ";")
-	iot = append(iot, "Server Tag,Scan Rate,Data Type,Deadband,Send Every Scan,Enabled,Use Scan Rate,")
-	for _, line := range plc {
-
-		if strings.Contains(line, "\"") && strings.Contains(line, ",") {
-			fields := strings.Split(line, ",")
-
-			tagName := fields[0][1 : len(fields[0])-1]
-
-			outLine := fmt.Sprintf("\"%s.%s\",%d,Byte Array,0.000000,0,1,1", connectionName, tagName, freq)
-			iot = append(iot, outLine)
-
+func findInput(w io.Writer, m *machineConfig, filename *string, candidates ...string) {
+	if *filename != "" {
+		return
+	}
+	for i := 0; i < len(candidates); i += 2 {
+		if fileExists(m.path(candidates[i])) {
+			fmt.Fprintf(w, "Found %s file - %s\n", candidates[i], candidates[i+1])
+			*filename = candidates[i]
+			return
 		}
 	}
-	return
 }
 
-// addSymToDBImage
+// loadProject - wczytanie plików wejściowych jednej maszyny (symbole, tagi HMI, alarmy, listy
+// tekstów, bloki poprzedniej generacji) i filtrów symboli; komunikaty trafiają do w
+// Zwraca kod wyjścia przy błędzie.
 // ================================================================================================
-func addSymToDBImage(sym Symbol) {
-
-	nr, err := strconv.Atoi(sym.sNr)
-	if ErrCheck2(err) {
-		found := false
-
-		var index int
-
-		for i, block := range dbBlocks {
-			if block.nr == nr {
-				found = true
-				index = i
-				break
-			}
-		}
+func loadProject(w io.Writer, m *machineConfig) (*tagsgen.Project, int, error) {
 
-		var adr int
-		n, err := fmt.Sscanf(sym.sAddHI, "%d", &adr)
-		if ErrCheck2(err) && n > 0 {
-
-			// fmt.Println(sym.sType)
-			var size byte
-			si, err := strconv.Atoi(sym.sSize)
-			if ErrCheck(err) && si <= 256 && si > 1 {
-				size = byte(si)
-			} else {
-				if sym.sType == "DBX" {
-					size = 1
-				}
-				if sym.sType == "DBB" {
-					size = 1
-				}
-				if sym.sType == "DBW" {
-					size = 2
-				}
-				if sym.sType == "DBD" {
-					size = 4
-				}
-			}
-
-			// if size > 0 {
+	// szukamy plików jeżeli nie zostały zdefiniowane
+	// ----------------------------------------------
+	findInput(w, m, &m.Symbols, "Symbols.asc", "Step7 symbols export file", "PLCTags.sdf", "TIA Portal PLC tags export file")
+	findInput(w, m, &m.Tags, "Tags.csv", "WinCCflexible Tags export file", "HMITags.xlsx", "TIA Portal HMI Tags export file")
+	findInput(w, m, &m.Alarms, "Alarms.csv", "WinCCflexible alarms export file", "HMIAlarms.xlsx", "TIA Portal alarms export file")
+	findInput(w, m, &m.TextLists, "Textlists.csv", "WinCCflexible text lists export file")
 
-			if !found {
-				var newBlock DBBlock
-				newBlock.nr = nr
-				dbBlocks = append(dbBlocks, newBlock)
-				index = len(dbBlocks) - 1
-				// fmt.Println("Nowy blok " + sym.sPer)
-			}
+	// wczytanie symboli, tagów HMI i alarmów
+	// ----------------------------------------------
+	project := tagsgen.NewProject()
 
-			// fmt.Println("Adres DB" + sym.sNr + "." + sym.sAddHI)
-			dbBlocks[index].tab[adr] = byte(size)
-			// }
+	if m.Symbols == "" && m.Tags == "" {
+		return nil, exitError, errors.New("no symbol table (-s) or HMI tags table (-t) found")
+	}
+	if len(m.Symbols) > 0 {
+		if err := project.LoadSymbolsFile(m.path(m.Symbols)); err != nil {
+			return nil, exitError, err
 		}
 	}
-}
-
-// generatePLC - funkcja generująca plik plc
-// "Inputs","IB0[64]",Byte Array,1,R/W,100,,,,,,,,,,"",
-// "Merkers","MB0[32]",Byte Array,1,R/W,100,,,,,,,,,,"",
-// "Outputs","QB0[32]",Byte Array,1,R/W,100,,,,,,,,,,"",
-// ================================================================================================
-func generatePLC(plcSymLine []string, hmiSymLine []string, bSize int, freq int, S7SymFilename string, FlexSymFilename string) (plc []string) {
-
-	plc = append(plc, "Tag Name,Address,Data Type,Respect Data Type,Client Access,Scan Rate,Scaling,Raw Low,Raw High,Scaled Low,Scaled High,Scaled Data Type,Clamp Low,Clamp High,Eng Units,Description,Negate Value")
-
-	var iImage [65536]byte
-	var mImage [65536]byte
-	var oImage [65536]byte
-
-	// Wypełnienie obrazów
-	for _, line := range plcSymLine {
-
-		// fmt.Println("Decoding  :", line)
-		// sSymbol, sPer, sAddHI, sAddLO, sType, sComment := decodeS7PLCSymLine(line)
-		// fmt.Println("Symbol    :", sSymbol)
-		// fmt.Println("Peripheral:", sPer)
-		// fmt.Println("AddressHI :", sAddHI)
-		// fmt.Println("AddressLO :", sAddLO)
-		// fmt.Println("Type      :", sType)
-		// fmt.Println("Comment   :", sComment)
-
-		line = DecodeWindows1250(line)
-
-		sSymbol, sPer, _, sAddHI, sAddLO, sType, sSize, sComment := decodeS7PLCSymLine(line, S7SymFilename)
-		var newSymbol = Symbol{sSymbol, sPer, sSize, sAddHI, sAddLO, sType, sSize, sComment}
-
-		// fmt.Println("PLC")
-		// fmt.Println("Symbol    :", sSymbol)
-		// fmt.Println("Peripheral:", sPer)
-		// fmt.Println("Size      :", sSize)
-		// fmt.Println("AddressHI :", sAddHI)
-		// fmt.Println("AddressLO :", sAddLO)
-		// fmt.Println("Type      :", sType)
-		// fmt.Println("Comment   :", sComment)
-
-		symbols = append(symbols, newSymbol)
+	if len(m.Tags) > 0 {
+		if err := project.LoadHmiTagsFile(m.path(m.Tags)); err != nil {
+			return nil, exitError, err
+		}
 	}
-
-	// Wypełnienie obrazów
-	for _, line := range hmiSymLine {
-
-		// fmt.Println("Decoding  :", line)
-		// sSymbol, sPer, sAddHI, sAddLO, sType, sComment := decodeS7PLCSymLine(line)
-		// fmt.Println("Symbol    :", sSymbol)
-		// fmt.Println("Peripheral:", sPer)
-		// fmt.Println("AddressHI :", sAddHI)
-		// fmt.Println("AddressLO :", sAddLO)
-		// fmt.Println("Type      :", sType)
-		// fmt.Println("Comment   :", sComment)
-
-		// line = DecodeWindows1250(line)
-
-		sSymbol, sPer, sNr, sAddHI, sAddLO, sType, sSize, sComment := decodeFlexTagSymLine(line, FlexSymFilename)
-		var newSymbol = Symbol{sSymbol, sPer, sNr, sAddHI, sAddLO, sType, sSize, sComment}
-
-		// fmt.Println("HMI")
-		// fmt.Println("Symbol    :", sSymbol)
-		// fmt.Println("Peripheral:", sPer)
-		// fmt.Println("AddressHI :", sAddHI)
-		// fmt.Println("AddressLO :", sAddLO)
-		// fmt.Println("Size      :", sSize)
-		// fmt.Println("Type      :", sType)
-		// fmt.Println("Comment   :", sComment)
-
-		symbols = append(symbols, newSymbol)
+	if len(m.Alarms) > 0 {
+		if err := project.LoadAlarmsFile(m.path(m.Alarms)); err != nil {
+			return nil, exitError, err
+		}
 	}
 
-	for _, sym := range symbols {
-		if len(sym.sAddHI) > 0 {
-			byteNr, err := strconv.ParseInt(sym.sAddHI, 10, 16)
-
-			if ErrCheck(err) && sym.sAddHI != "" {
-				if sym.sPer == "I" || sym.sPer == "IB" {
-					iImage[byteNr] = 1
-				}
-				if sym.sPer == "IW" {
-					iImage[byteNr] = 2
-				}
-				if sym.sPer == "ID" {
-					iImage[byteNr] = 4
-				}
-
-				if sym.sPer == "M" || sym.sPer == "MB" {
-					mImage[byteNr] = 1
-				}
-				if sym.sPer == "MW" {
-					mImage[byteNr] = 2
-				}
-				if sym.sPer == "MD" {
-					mImage[byteNr] = 4
-				}
-
-				if sym.sPer == "Q" || sym.sPer == "QB" {
-					oImage[byteNr] = 1
-				}
-				if sym.sPer == "QW" {
-					oImage[byteNr] = 2
-				}
-				if sym.sPer == "QD" {
-					oImage[byteNr] = 4
-				}
-
-				if strings.Contains(sym.sPer, "DB") {
-					addSymToDBImage(sym)
-				}
-
-			}
+	if len(m.TextLists) > 0 {
+		if err := project.LoadTextListsFile(m.path(m.TextLists)); err != nil {
+			return nil, exitError, err
 		}
 	}
-
-	// Pakowanie w bloki
-	plc = append(plc, prepPLCImageBlocks(iImage, "IB", bSize, freq)...)
-	plc = append(plc, prepPLCImageBlocks(mImage, "MB", bSize, freq)...)
-	plc = append(plc, prepPLCImageBlocks(oImage, "QB", bSize, freq)...)
-
-	for _, block := range dbBlocks {
-		bb := block.tab
-		plc = append(plc, prepPLCImageBlocks(bb, "DB"+strconv.Itoa(block.nr), bSize, freq)...)
+	if len(m.TextListLinks) > 0 {
+		if len(m.TextLists) == 0 {
+			return nil, exitUsage, errors.New("text list links (-textlist-links) without text lists (-textlists)")
+		}
+		if err := project.LoadTextListLinksFile(m.path(m.TextListLinks)); err != nil {
+			return nil, exitError, err
+		}
 	}
 
-	return
-}
-
-// generateTagsFromSymbols - Przetworzenie symboli na wskaźniki do tablicy tagów
-// ================================================================================================
-func generateTagsFromSymbols(connName string) {
-
-	tags.ConnectionName = connName
-	tags.Timestamp = time.Now().Unix()
-
-	if len(symbols) > 0 {
-
-		for _, sym := range symbols {
-			// fmt.Println(sym.sSymbol, sym.sType, sym.sPer, sym.sAddHI, sym.sAddLO, sym.sSize)
-
-			if sym.sType != "FB" && sym.sType != "DB" && sym.sType != "FC" && sym.sType != "TIMER" && sym.sType != "UDT" {
-
-				var comment []string
-				comment = append(comment, sym.sComment)
-
-				// type CsvTag struct {
-				// 	Index   int
-				// }
-
-				size, _ := strconv.Atoi(sym.sSize)
-				loAddress, _ := strconv.Atoi(sym.sAddLO)
-				hiAddress, _ := strconv.Atoi(sym.sAddHI)
-
-				var index int
-				var name string
-
-				// szukamy tego bitu w tablicy wygenerowanej dla PLC
-				// -----------------------------------------------
-
-				found := false
-				for _, t := range kepTags {
-
-					if hiAddress >= t.StartingIndex && hiAddress < t.StartingIndex+t.Size {
-
-						if t.Type == sym.sPer ||
-							t.Type == "IB" && sym.sPer == "I" ||
-							t.Type == "IB" && sym.sPer == "IB" ||
-							t.Type == "IB" && sym.sPer == "IW" ||
-							t.Type == "IB" && sym.sPer == "ID" ||
-							t.Type == "MB" && sym.sPer == "M" ||
-							t.Type == "MB" && sym.sPer == "MB" ||
-							t.Type == "MB" && sym.sPer == "MW" ||
-							t.Type == "MB" && sym.sPer == "MD" ||
-							t.Type == "QB" && sym.sPer == "Q" ||
-							t.Type == "QB" && sym.sPer == "QB" ||
-							t.Type == "QB" && sym.sPer == "QW" ||
-							t.Type == "QB" && sym.sPer == "QD" {
-
-							// fmt.Println("Type: " + t.Type + " vs " + sym.sType + " vs " + sym.sPer)
-
-							index = hiAddress - t.StartingIndex
-							name = fmt.Sprintf("tab%s_%d", t.Type, t.StartingIndex)
-							found = true
-							break
-						}
-					}
-
-				}
-
-				if found {
-					data := CsvTag{
-						SymbolName:      sym.sSymbol,
-						SymbolPeriph:    sym.sPer,
-						SymbolAddressHi: sym.sAddHI,
-						SymbolAddressLo: sym.sAddLO,
-						Comment:         sym.sComment,
-						TagName:         name,
-						Size:            size,
-						BitNr:           loAddress,
-						Index:           index,
-					}
-
-					tags.Tags = append(tags.Tags, data)
-
-				}
-
-			}
+	if len(m.Baseline) > 0 {
+		if err := project.LoadBaselineFile(m.path(m.Baseline)); err != nil {
+			return nil, exitError, err
 		}
 	}
-}
-
-// parseFlexAlarms - Przetworzenie alarmów z WinccFlex na wskaźniki do tablicy tagów
-// ================================================================================================
-func parseFlexAlarms(alarms []string, connName string, srcFile string) Alarms {
-
-	var tempAlarms Alarms
-
-	if len(srcFile) > 0 {
-
-		tempAlarms.ConnectionName = connName
-		tempAlarms.Timestamp = time.Now().Unix()
-		tempAlarms.SourceFilename = srcFile
-		if len(alarms) > 0 {
-			tempAlarms.SourceInfo = alarms[0]
-
-			// Loop through lines & turn into object
-			for _, alarm := range alarms {
-
-				// fmt.Println(line)
-
-				if !strings.Contains(alarm, "#") && !strings.Contains(alarm, "//") && alarm != "" {
-					fields := strings.Split(alarm, "\t")
-
-					// fmt.Println(fields)
-
-					alarmNumber, _ := strconv.Atoi(strings.ReplaceAll(fields[1], "\"", ""))
-					triggerBitNr, _ := strconv.Atoi(strings.ReplaceAll(fields[4], "\"", ""))
-					triggerTag := strings.ReplaceAll(fields[3], "\"", "")
-
-					// szukamy tego taga alarmu w tagach HMI
-					for _, sym := range symbols {
-
-						if sym.sSymbol == triggerTag {
-
-							// fmt.Println(triggerTag)
-
-							// szukamy tego bitu w tablicy wygenerowanej dla PLC
-							// -----------------------------------------------
-
-							for _, t := range kepTags {
-
-								tagAddress, _ := strconv.Atoi(sym.sAddHI)
-
-								// fmt.Println(tag.sSymbol + " " + tag.sType)
-								if t.StartingIndex == tagAddress && t.Type == sym.sPer {
-
-									triggerByte := (triggerBitNr / 8) ^ 1
-									bitNr := triggerBitNr % 8
 
-									// if triggerByte >= t.StartingIndex && triggerByte < t.StartingIndex+t.Size {
-									if triggerByte < t.Size {
+	printParseErrors(w, project.ParseErrors)
 
-										var texts []string
-
-										for i := 11; i < 18; i++ {
-											if len(fields[i]) > 7 {
-												texts = append(texts, strings.ReplaceAll(fields[i], "\"", ""))
-											}
-										}
-
-										// fmt.Println(texts)
-										// fmt.Println(fmt.Sprintf("sym %s %s.%s[%s]", triggerTag, sym.sPer, sym.sAddHI, sym.sSize))
-										// fmt.Println(fmt.Sprintf("tag %s %s.%d[%d]", triggerTag, t.Type, t.StartingIndex, triggerByte))
-										// fmt.Println(fmt.Sprintf("tab%s_%d[%d].%d", t.Type, t.StartingIndex, triggerByte, bitNr))
-
-										tagName := fmt.Sprintf("tab%s_%d", t.Type, t.StartingIndex)
-
-										// dodajemy do globalnej tablicy alarmów
-										data := CsvAlarm{
-											Number:  alarmNumber,
-											Texts:   texts,
-											TagName: tagName,
-											Index:   triggerByte,
-											BitNr:   bitNr,
-										}
-										tempAlarms.Alarms = append(tempAlarms.Alarms, data)
-										// -----------------------------------------------
-										break
-									}
-
-								}
-							}
-							break
-						}
-					}
+	if m.Strict && len(project.ParseErrors) > 0 {
+		return nil, exitStrict, fmt.Errorf("strict mode: %d lines of input files could not be parsed, no files written", len(project.ParseErrors))
+	}
 
-				}
-			}
+	if len(m.Include) > 0 || len(m.Exclude) > 0 {
+		removed, err := project.FilterSymbols(m.Include, m.Exclude)
+		if err != nil {
+			return nil, exitUsage, err
 		}
+		fmt.Fprintf(w, "Symbol filters: %d symbols skipped, %d left\n", removed, len(project.Symbols))
 	}
-	return tempAlarms
-}
 
-// fileExists - sprawdzenie czy plik istnieje
-// ================================================================================================
-func fileExists(filename string) bool {
-
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return false
+	if len(project.Symbols) == 0 {
+		return nil, exitError, errors.New("no symbols loaded from input files")
 	}
 
-	return true
+	return project, 0, nil
 }
 
-// main - program entry point
+// generateMachine - wczytanie plików wejściowych, generowanie i zapis plików wyjściowych jednej
+// maszyny; zwraca kod wyjścia przy błędzie
 // ================================================================================================
-func main() {
+func generateMachine(m *machineConfig) (int, error) {
 
-	fmt.Println("=============================================================================================")
-	fmt.Println("==                         Siemens PLC Tags generator / DTP                                ==")
-	fmt.Println("==    Generator of Tags in form of csv configuration files for KepServerEX6 + IoTGateway   ==")
-	fmt.Println("=============================================================================================")
-	fmt.Println()
-
-	hmiTagsFilename := flag.String("t", "", "WinCCflexible (Tags.csv) or TIA Portal (HMITags.xlsx) HMI Tags table filename (input)")
-	hmiAlarmsFilename := flag.String("a", "", "WinCCflexible (Alarms.csv) or TIA Portal (HMIAlarms.xlsx) alarms table filename (input)")
-	symFilename := flag.String("s", "", "Step7 (Symbols.asc) or TIA Portal (PLCTags.sdf) symbol table filename (input)")
-	plcFilename := flag.String("p", "plc.csv", "PLC Tags filename (output)")
-	iotFilename := flag.String("i", "iot.csv", "IoT Gateway Tags filename (output)")
-	connectionName := flag.String("c", "SiemensTCPIP.PLC", "Connection description")
-	blockSize := flag.Int("b", 8, "Block size in [bytes]")
-	pollFreq := flag.Int("f", 100, "Frequency of polling in [ms]")
-
-	flag.Parse()
-
-	// szukamy plików jeżeli nie zostały zdefiniowane
-	// ----------------------------------------------
-	if *symFilename == "" {
-		if fileExists("Symbols.asc") {
-			fmt.Println("Found Symbols.asc file - Step7 symbols export file")
-			*symFilename = "Symbols.asc"
+	for _, o := range m.Outputs {
+		known := false
+		for _, k := range allOutputs {
+			known = known || strings.EqualFold(o, k)
 		}
-	}
-	if *hmiTagsFilename == "" {
-		if fileExists("Tags.csv") {
-			fmt.Println("Found Tags.csv file - WinCCflexible Tags export file")
-			*hmiTagsFilename = "Tags.csv"
+		if !known {
+			return exitUsage, fmt.Errorf("unknown output %q, expected one of %s", o, strings.Join(allOutputs, ", "))
 		}
 	}
-	if *hmiAlarmsFilename == "" {
-		if fileExists("Alarms.csv") {
-			fmt.Println("Found Alarms.csv file - WinCCflexible alarms export file")
-			*hmiAlarmsFilename = "Alarms.csv"
-		}
+
+	project, code, err := loadProject(os.Stdout, m)
+	if err != nil {
+		return code, err
 	}
 
 	// pliki plc+iot dla kepware
 	// ----------------------------------------------
-	plcSymIn, _ := readLines(*symFilename)
-	hmiSymIn, _ := readLinesUTF16(*hmiTagsFilename)
-
-	plcOut := generatePLC(plcSymIn, hmiSymIn, *blockSize, *pollFreq, *symFilename, *hmiTagsFilename)
-	iotOut := generateIOT(plcOut, *connectionName, *pollFreq)
-
-	fmt.Println("Generating Kepware import files: " + *plcFilename + ", " + *iotFilename + " ...")
-
-	writeLines(plcOut, *plcFilename)
-	writeLines(iotOut, *iotFilename)
-
-	// fmt.Println(symbols)
-
-	// alarmy wincc_flexible
-	// ----------------------------------------------
-	if len(*hmiAlarmsFilename) > 0 {
-		fmt.Println("Generating alarms description file: alarms.json ...")
-		hmiAlarmsIn, _ := readLinesUTF16(*hmiAlarmsFilename)
-		alarms = parseFlexAlarms(hmiAlarmsIn, *connectionName, *hmiAlarmsFilename)
-
-		file, _ := json.MarshalIndent(alarms, "", " ")
-		_ = ioutil.WriteFile("alarms.json", file, 0666)
+	result, err := project.Generate(m.options())
+	if err != nil {
+		return exitUsage, err
 	}
 
-	// for _, a := range alarms {
-	// 	fmt.Println(a)
-	// }
+	if m.Optimize {
+		printBlockReport(result.BlockReport)
+	}
+	if len(m.Baseline) > 0 && m.Mode == tagsgen.ModeSymbols {
+		fmt.Fprintln(os.Stderr, "WARNING: -baseline is ignored in -mode symbols (no blocks)")
+	} else if len(m.Baseline) > 0 {
+		printBlockChanges(result.BlockChanges)
+	}
+	printDuplicateSymbols(result.DuplicateSymbols)
+	printUnresolvedAlarms(result.UnresolvedAlarms)
+
+	outputs := []struct {
+		output   string
+		filename string
+		text     string
+		write    func(path string) error
+	}{
+		{outputPLC, m.PLC, "Kepware PLC tags", func(path string) error { return writeLines(result.PLC, path) }},
+		{outputIOT, m.IOT, "IoT Gateway items", func(path string) error { return writeLines(result.IOT, path) }},
+		{outputChannel, m.ChannelFile, "Kepware channel and device definition", func(path string) error { return writeJSON(result.Channel, path) }},
+		{outputAgent, m.AgentFile, "IoT Gateway agent definition", func(path string) error { return writeJSON(result.Agent, path) }},
+		{outputAlarms, m.AlarmsJSON, "alarms description file", func(path string) error { return writeJSON(result.Alarms, path) }},
+		{outputTags, m.TagsJSON, "tags description file", func(path string) error { return writeJSON(result.Tags, path) }},
+		{outputUnresolved, m.UnresolvedFile, "unresolved alarms report", func(path string) error {
+			unresolved := result.UnresolvedAlarms
+			if unresolved == nil {
+				unresolved = []tagsgen.UnresolvedAlarm{}
+			}
+			return writeJSON(unresolved, path)
+		}},
+	}
 
-	// tagi step7+wincc_flexible
-	// ----------------------------------------------
-	fmt.Println("Generating tags description file: tags.json ...")
+	// z -fail-unresolved zapisywany jest tylko raport pominiętych alarmów
+	abort := m.FailUnresolved && len(result.UnresolvedAlarms) > 0
 
-	generateTagsFromSymbols(*connectionName)
+	for _, o := range outputs {
+		switch {
+		case !m.writes(o.output),
+			abort && o.output != outputUnresolved,
+			o.output == outputChannel && result.Channel == nil,
+			o.output == outputAgent && result.Agent == nil,
+			(o.output == outputAlarms || o.output == outputUnresolved) && len(m.Alarms) == 0:
+			continue
+		}
+		fmt.Println("Generating " + o.text + ": " + m.path(o.filename) + " ...")
+		if err := o.write(m.path(o.filename)); err != nil {
+			return exitError, err
+		}
+	}
 
-	file, _ := json.MarshalIndent(tags, "", " ")
-	_ = ioutil.WriteFile("tags.json", file, 0666)
+	if abort {
+		return exitUnresolved, fmt.Errorf("%d alarms could not be resolved, no files written except %s", len(result.UnresolvedAlarms), m.path(m.UnresolvedFile))
+	}
 
+	return 0, nil
 }